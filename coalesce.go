@@ -0,0 +1,166 @@
+package gqlclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// CoalesceRequests makes concurrent runs of identical queries share a
+// single network call: while a query is in flight, runs of the same query,
+// with the same variables and headers and for the same endpoint, wait for
+// its response instead of sending their own. Each of them then decodes the
+// shared response into its own resp, and gets its own copy of the
+// http.Response, whose body, kept with PreserveResponseBody, it can read
+// without draining it for the others.
+//
+// The headers set by WithPropagator from the context of each run, such as
+// credentials or a tenant, are part of what makes runs identical, so that
+// no run gets a response fetched for another identity.
+//
+// Only queries are coalesced, as mutations must run as many times as they
+// are sent, and requests with files or a raw body, as well as runs of
+// RunFull, are always sent on their own.
+//
+// The shared call belongs to no single run: a run whose context is done
+// stops waiting for it and returns the context error, while the others
+// keep waiting. The call is only cancelled once every run sharing it has
+// left. It carries the context values of the run that started it.
+//
+//	NewClient(endpoint, CoalesceRequests())
+func CoalesceRequests() ClientOption {
+	return func(client *Client) {
+		client.coalesce = true
+	}
+}
+
+// flight is a coalesced request in flight.
+type flight struct {
+	done  chan struct{}
+	res   *http.Response
+	body  []byte
+	data  json.RawMessage
+	stats Stats
+	err   error
+
+	// waiters counts the runs waiting for the flight, which is cancelled
+	// when the last of them leaves
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// coalesceKey gets the key identifying the runs that req can share a
+// network call with, or false if it cannot share one.
+func (c *Client) coalesceKey(ctx context.Context, req *Request, stats *Stats) (string, bool) {
+	if !c.coalesce || stats.envelope != nil || len(req.files) > 0 || req.rawBody != nil || req.err != nil {
+		return "", false
+	}
+	if req.OperationType() != "query" {
+		return "", false
+	}
+	// encoding/json sorts map keys, which makes the key canonical. The
+	// content is used as is rather than hashed, so that distinct requests
+	// never share a call.
+	var propagated http.Header
+	if c.propagate != nil {
+		propagated = make(http.Header)
+		c.propagate(ctx, propagated)
+	}
+	key, err := json.Marshal(struct {
+		Endpoint   string
+		Key        string
+		Header     http.Header
+		Propagated http.Header
+	}{
+		Endpoint:   c.endpointFor(req),
+		Key:        string(req.keyBytes()),
+		Header:     req.Header,
+		Propagated: propagated,
+	})
+	if err != nil {
+		return "", false
+	}
+	return string(key), true
+}
+
+// runCoalesced runs req, or waits for the run of an identical request
+// already in flight, and decodes the response into resp.
+func (c *Client) runCoalesced(ctx context.Context, key string, req *Request, resp interface{}, stats *Stats) (*http.Response, error) {
+	c.flightsMu.Lock()
+	f, joined := c.flights[key]
+	if !joined {
+		// the call keeps the values of ctx, but not its cancellation
+		flightCtx, cancel := context.WithCancel(valuesContext{Context: context.Background(), base: ctx})
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		if c.flights == nil {
+			c.flights = make(map[string]*flight)
+		}
+		c.flights[key] = f
+		go func() {
+			defer cancel()
+			f.res, f.err = c.runRequest(flightCtx, req, &f.data, &f.stats)
+			if f.res != nil && c.preserveBody {
+				// each run gets its own reader of the body
+				f.body, _ = io.ReadAll(f.res.Body)
+			}
+			c.leaveFlight(key, f)
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	c.flightsMu.Unlock()
+
+	if joined {
+		c.logf("coalesced with a request in flight")
+	}
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		c.flightsMu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			c.leaveFlightLocked(key, f)
+		}
+		c.flightsMu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	*stats = f.stats
+	res := f.response()
+	if resp != nil && len(f.data) > 0 {
+		if err := json.Unmarshal(f.data, resp); err != nil {
+			return res, c.decodeError(res, err, f.data)
+		}
+	}
+	return res, f.err
+}
+
+// response gets a shallow copy of the response of the flight, with its own
+// body when it is preserved.
+func (f *flight) response() *http.Response {
+	if f.res == nil {
+		return nil
+	}
+	res := *f.res
+	if f.body != nil {
+		res.Body = io.NopCloser(bytes.NewReader(f.body))
+	}
+	return &res
+}
+
+// leaveFlight removes f from the flights in progress, unless another
+// flight took its place.
+func (c *Client) leaveFlight(key string, f *flight) {
+	c.flightsMu.Lock()
+	defer c.flightsMu.Unlock()
+	c.leaveFlightLocked(key, f)
+}
+
+func (c *Client) leaveFlightLocked(key string, f *flight) {
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+}
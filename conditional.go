@@ -0,0 +1,444 @@
+package gqlclient
+
+import (
+	"sort"
+	"strings"
+)
+
+// ResolveConditionalDirectives makes the Client resolve @include and @skip
+// directives itself, before sending a query, when their condition is known
+// without the server: a true or false literal, or a variable the Request
+// does not set. An unset variable takes the default value of its
+// definition, or false if it has none. Fields and fragments excluded by
+// their directives are pruned from the query, and the directives of the
+// ones that stay are dropped, along with the definitions of the fragments
+// and variables no longer used.
+//
+// Directives whose condition is a variable set on the Request are left for
+// the server to resolve. Requests with a raw body are sent as they are.
+//
+//	NewClient(endpoint, ResolveConditionalDirectives())
+func ResolveConditionalDirectives() ClientOption {
+	return func(client *Client) {
+		client.resolveConditionals = true
+	}
+}
+
+// edit replaces doc[start:end] with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// varDefinition is a variable definition of an operation.
+type varDefinition struct {
+	name string
+
+	// start and end are the indexes of its first and last tokens
+	start, end int
+
+	// defaultValue is the default of a Boolean variable, if it has one
+	defaultValue, hasDefault bool
+}
+
+// varDefinitions are the variable definitions of an operation, between
+// the parentheses at tokens[open] and tokens[close].
+type varDefinitions struct {
+	open, close int
+	defs        []varDefinition
+}
+
+// fragmentDefinition is a fragment definition of the document.
+type fragmentDefinition struct {
+	name string
+
+	// start and end are the indexes of its first token and of the one
+	// following it
+	start, end int
+}
+
+// conditionals resolves the @include and @skip directives of a document.
+type conditionals struct {
+	tokens    []token
+	variables map[string]interface{}
+	defs      map[string][]varDefinition
+	varLists  []varDefinitions
+	fragments []fragmentDefinition
+	edits     []edit
+
+	// pruned holds the token ranges removed from the document
+	pruned [][2]int
+}
+
+// resolveConditionals prunes doc according to the @include and @skip
+// directives whose condition does not depend on variables.
+func resolveConditionals(doc string, variables map[string]interface{}) string {
+	c := &conditionals{
+		tokens:    tokenize(doc),
+		variables: variables,
+		defs:      make(map[string][]varDefinition),
+	}
+	var sets []int
+	depth := 0
+	for i := 0; i < len(c.tokens); i++ {
+		t := c.tokens[i]
+		if t.kind == tokenPunct {
+			switch t.value {
+			case "{":
+				if depth == 0 {
+					// shorthand query
+					sets = append(sets, i)
+				}
+				depth++
+			case "(", "[":
+				depth++
+			case "}", ")", "]":
+				depth--
+			}
+			continue
+		}
+		if depth != 0 || t.kind != tokenName {
+			continue
+		}
+		switch t.value {
+		case "query", "mutation", "subscription":
+			i = c.parseVarDefinitions(i + 1)
+			i = selectionSetStart(c.tokens, i)
+			sets = append(sets, i)
+			depth++
+		case "fragment":
+			start := i
+			i = selectionSetStart(c.tokens, i+1)
+			if start+1 < len(c.tokens) {
+				c.fragments = append(c.fragments, fragmentDefinition{
+					name:  c.tokens[start+1].value,
+					start: start,
+					end:   skipBalanced(c.tokens, i),
+				})
+			}
+			sets = append(sets, i)
+			depth++
+		}
+	}
+	if !hasConditionals(c.tokens) {
+		return doc
+	}
+	used := c.usedVariables()
+	spread := c.usedFragments()
+	for _, i := range sets {
+		c.selectionSet(i)
+	}
+	if len(c.edits) == 0 {
+		return doc
+	}
+	// fragments go first, as they may hold the last references to
+	// variables
+	c.pruneFragments(spread)
+	c.pruneVarDefinitions(used)
+
+	sort.Slice(c.edits, func(i, j int) bool {
+		return c.edits[i].start < c.edits[j].start
+	})
+	var b strings.Builder
+	pos := 0
+	for _, e := range c.edits {
+		b.WriteString(doc[pos:e.start])
+		b.WriteString(e.text)
+		pos = e.end
+	}
+	b.WriteString(doc[pos:])
+	return b.String()
+}
+
+// hasConditionals reports whether tokens hold an @include or @skip
+// directive.
+func hasConditionals(tokens []token) bool {
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].is(tokenPunct, "@") && (tokens[i+1].is(tokenName, "include") || tokens[i+1].is(tokenName, "skip")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVarDefinitions reads the variable definitions of the operation whose
+// name, if any, is at tokens[i], and returns the index following them.
+func (c *conditionals) parseVarDefinitions(i int) int {
+	tokens := c.tokens
+	if i < len(tokens) && tokens[i].kind == tokenName {
+		i++
+	}
+	if i >= len(tokens) || !tokens[i].is(tokenPunct, "(") {
+		return i
+	}
+	list := varDefinitions{open: i}
+	i++
+	for i+1 < len(tokens) && tokens[i].is(tokenPunct, "$") {
+		def := varDefinition{name: tokens[i+1].value, start: i}
+		i += 2
+		depth := 0
+		for ; i < len(tokens); i++ {
+			t := tokens[i]
+			if depth == 0 && (t.is(tokenPunct, "$") || t.is(tokenPunct, ")")) {
+				break
+			}
+			switch {
+			case t.is(tokenPunct, "(") || t.is(tokenPunct, "[") || t.is(tokenPunct, "{"):
+				depth++
+			case t.is(tokenPunct, ")") || t.is(tokenPunct, "]") || t.is(tokenPunct, "}"):
+				depth--
+			case depth == 0 && t.is(tokenPunct, "=") && i+1 < len(tokens):
+				switch v := tokens[i+1]; {
+				case v.is(tokenName, "true"):
+					def.defaultValue, def.hasDefault = true, true
+				case v.is(tokenName, "false"):
+					def.hasDefault = true
+				}
+			}
+		}
+		def.end = i - 1
+		c.defs[def.name] = append(c.defs[def.name], def)
+		list.defs = append(list.defs, def)
+	}
+	if i < len(tokens) && tokens[i].is(tokenPunct, ")") {
+		list.close = i
+		c.varLists = append(c.varLists, list)
+		i++
+	}
+	return i
+}
+
+// selectionSet resolves the directives of the selection set opening at
+// tokens[i], and returns the index of the token closing it.
+func (c *conditionals) selectionSet(i int) int {
+	tokens := c.tokens
+	if i >= len(tokens) || !tokens[i].is(tokenPunct, "{") {
+		return i
+	}
+	i++
+	var kept, pruned int
+	lastPruned := -1
+	for i < len(tokens) && !tokens[i].is(tokenPunct, "}") {
+		start := i
+		var keep bool
+		i, keep = c.selection(i)
+		if i == start {
+			// not a selection, leave the rest of the document as is
+			i++
+			continue
+		}
+		if keep {
+			kept++
+			continue
+		}
+		pruned++
+		lastPruned = len(c.edits)
+		c.pruned = append(c.pruned, [2]int{start, i})
+		c.edits = append(c.edits, edit{tokens[start].pos, tokens[i-1].end(), ""})
+	}
+	if kept == 0 && pruned > 0 {
+		// an empty selection set is invalid
+		c.edits[lastPruned].text = "__typename"
+	}
+	return i + 1
+}
+
+// selection resolves the directives of the selection starting at
+// tokens[i], and returns the index following it, and whether it is kept.
+func (c *conditionals) selection(i int) (int, bool) {
+	tokens := c.tokens
+	switch {
+	case tokens[i].is(tokenPunct, "..."):
+		i++
+		if i+1 < len(tokens) && tokens[i].is(tokenName, "on") && tokens[i+1].kind == tokenName {
+			i += 2
+		} else if i < len(tokens) && tokens[i].kind == tokenName {
+			i++
+		}
+	case tokens[i].kind == tokenName:
+		i++
+		if i+1 < len(tokens) && tokens[i].is(tokenPunct, ":") {
+			i += 2
+		}
+		if i < len(tokens) && tokens[i].is(tokenPunct, "(") {
+			i = skipBalanced(tokens, i)
+		}
+	default:
+		return i, true
+	}
+	keep := true
+	var resolved []edit
+	for i+1 < len(tokens) && tokens[i].is(tokenPunct, "@") {
+		start := i
+		name := tokens[i+1].value
+		i += 2
+		if i >= len(tokens) || !tokens[i].is(tokenPunct, "(") {
+			continue
+		}
+		end := skipBalanced(tokens, i)
+		if name == "include" || name == "skip" {
+			if value, ok := c.condition(tokens[i+1 : end-1]); ok {
+				keep = keep && value == (name == "include")
+				resolved = append(resolved, edit{tokens[start].pos, tokens[end-1].end(), ""})
+				c.pruned = append(c.pruned, [2]int{start, end})
+			}
+		}
+		i = end
+	}
+	if !keep {
+		if i < len(tokens) && tokens[i].is(tokenPunct, "{") {
+			i = skipBalanced(tokens, i)
+		}
+		return i, false
+	}
+	c.edits = append(c.edits, resolved...)
+	if i < len(tokens) && tokens[i].is(tokenPunct, "{") {
+		i = c.selectionSet(i)
+	}
+	return i, true
+}
+
+// condition gets the value of the if argument of an @include or @skip
+// directive, held by args, and whether it is known: it is not when it
+// depends on a variable set by the Request.
+func (c *conditionals) condition(args []token) (bool, bool) {
+	if len(args) < 3 || !args[0].is(tokenName, "if") || !args[1].is(tokenPunct, ":") {
+		return false, false
+	}
+	switch v := args[2]; {
+	case len(args) == 3 && v.is(tokenName, "true"):
+		return true, true
+	case len(args) == 3 && v.is(tokenName, "false"):
+		return false, true
+	case len(args) == 4 && v.is(tokenPunct, "$") && args[3].kind == tokenName:
+		name := args[3].value
+		if _, ok := c.variables[name]; ok {
+			return false, false
+		}
+		defs := c.defs[name]
+		if len(defs) == 0 {
+			return false, true
+		}
+		for _, def := range defs[1:] {
+			if def.hasDefault != defs[0].hasDefault || def.defaultValue != defs[0].defaultValue {
+				// operations disagree on the default
+				return false, false
+			}
+		}
+		return defs[0].defaultValue, true
+	}
+	return false, false
+}
+
+// usedVariables counts the references to each variable, outside of
+// variable definitions and of the ranges already pruned.
+func (c *conditionals) usedVariables() map[string]int {
+	defined := make(map[int]bool)
+	for _, defs := range c.defs {
+		for _, def := range defs {
+			defined[def.start] = true
+		}
+	}
+	used := make(map[string]int)
+	for i := 0; i+1 < len(c.tokens); i++ {
+		if !c.tokens[i].is(tokenPunct, "$") || defined[i] || c.isPruned(i) {
+			continue
+		}
+		used[c.tokens[i+1].value]++
+	}
+	return used
+}
+
+// pruneVarDefinitions drops the definitions of the variables whose only
+// references were pruned, given the references counted before pruning.
+func (c *conditionals) pruneVarDefinitions(before map[string]int) {
+	after := c.usedVariables()
+	for _, list := range c.varLists {
+		var unused []varDefinition
+		for _, def := range list.defs {
+			if before[def.name] > 0 && after[def.name] == 0 {
+				unused = append(unused, def)
+			}
+		}
+		if len(unused) > 0 && len(unused) == len(list.defs) {
+			// empty parentheses are invalid
+			c.edits = append(c.edits, edit{c.tokens[list.open].pos, c.tokens[list.close].end(), ""})
+			continue
+		}
+		for _, def := range unused {
+			c.edits = append(c.edits, edit{c.tokens[def.start].pos, c.tokens[def.end].end(), ""})
+		}
+	}
+}
+
+// usedFragments counts the spreads of each fragment, outside of the ranges
+// already pruned.
+func (c *conditionals) usedFragments() map[string]int {
+	used := make(map[string]int)
+	for i := 0; i+1 < len(c.tokens); i++ {
+		if !c.tokens[i].is(tokenPunct, "...") || c.tokens[i+1].kind != tokenName || c.tokens[i+1].value == "on" || c.isPruned(i) {
+			continue
+		}
+		used[c.tokens[i+1].value]++
+	}
+	return used
+}
+
+// pruneFragments drops the definitions of the fragments whose only spreads
+// were pruned, given the spreads counted before pruning, as unused
+// fragments make a document invalid. Dropping a fragment prunes the
+// spreads it holds, so this repeats until every fragment left is used.
+func (c *conditionals) pruneFragments(before map[string]int) {
+	dropped := make(map[int]bool)
+	for changed := true; changed; {
+		changed = false
+		after := c.usedFragments()
+		for k, f := range c.fragments {
+			if dropped[k] || before[f.name] == 0 || after[f.name] > 0 {
+				continue
+			}
+			dropped[k], changed = true, true
+			c.pruned = append(c.pruned, [2]int{f.start, f.end})
+			start, end := c.tokens[f.start].pos, c.tokens[f.end-1].end()
+			// the edits within the fragment go with it
+			kept := c.edits[:0]
+			for _, e := range c.edits {
+				if e.start < start || e.end > end {
+					kept = append(kept, e)
+				}
+			}
+			c.edits = append(kept, edit{start, end, ""})
+		}
+	}
+}
+
+func (c *conditionals) isPruned(i int) bool {
+	for _, r := range c.pruned {
+		if i >= r[0] && i < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// skipBalanced returns the index following the bracket closing the one at
+// tokens[i].
+func skipBalanced(tokens []token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].value {
+		case "{", "(", "[":
+			if tokens[i].kind == tokenPunct {
+				depth++
+			}
+		case "}", ")", "]":
+			if tokens[i].kind == tokenPunct {
+				depth--
+			}
+		}
+		if depth == 0 {
+			return i + 1
+		}
+	}
+	return i
+}
@@ -0,0 +1,211 @@
+package gqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestCoalesceRequests(t *testing.T) {
+	is := is.New(t)
+
+	var calls int64
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		io.WriteString(w, `{"data":{"user":{"name":"lelebus"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL, CoalesceRequests())
+
+	var wg sync.WaitGroup
+	responses := make([]map[string]interface{}, 5)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := NewRequest(`query ($id: ID!, $full: Boolean) { user(id: $id) { name } }`).
+				WithVars(map[string]interface{}{"id": "1", "full": true})
+			_, err := client.Run(ctx, req, &responses[i])
+			is.NoErr(err)
+		}(i)
+	}
+	// wait for the runs to join the first one
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	is.Equal(atomic.LoadInt64(&calls), int64(1))
+	for _, response := range responses {
+		is.Equal(response["user"], map[string]interface{}{"name": "lelebus"})
+	}
+	responses[0]["user"] = nil
+	is.True(responses[1]["user"] != nil) // each run decodes its own copy
+
+	// mutations are never coalesced
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Run(ctx, NewRequest(`mutation { touch }`), nil)
+			is.NoErr(err)
+		}()
+	}
+	wg.Wait()
+	is.Equal(atomic.LoadInt64(&calls), int64(3))
+}
+
+func TestCoalesceRequestsCancel(t *testing.T) {
+	is := is.New(t)
+
+	release := make(chan struct{})
+	cancelled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		select {
+		case <-release:
+			io.WriteString(w, `{"data":{"user":{"name":"lelebus"}}}`)
+		case <-r.Context().Done():
+			close(cancelled)
+		}
+	}))
+	defer srv.Close()
+	client := NewClient(srv.URL, CoalesceRequests())
+
+	// the first run leaves, the one that joined it still gets the data
+	first, cancelFirst := context.WithCancel(context.Background())
+	firstDone := make(chan error)
+	go func() {
+		_, err := client.Run(first, NewRequest(`query { user { name } }`), nil)
+		firstDone <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	var resp map[string]interface{}
+	joinerDone := make(chan error)
+	go func() {
+		_, err := client.Run(context.Background(), NewRequest(`query { user { name } }`), &resp)
+		joinerDone <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancelFirst()
+	is.Equal(<-firstDone, context.Canceled)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	is.NoErr(<-joinerDone)
+	is.Equal(resp["user"], map[string]interface{}{"name": "lelebus"})
+
+	// the call is cancelled once every run left
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	release = make(chan struct{})
+	_, err := client.Run(ctx, NewRequest(`query { user { name } }`), nil)
+	is.Equal(err, context.DeadlineExceeded)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("shared call not cancelled")
+	}
+}
+
+func TestCoalesceRequestsDistinct(t *testing.T) {
+	is := is.New(t)
+
+	var calls int64
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Query string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&params))
+		atomic.AddInt64(&calls, 1)
+		<-release
+		fmt.Fprintf(w, `{"data":{"query":%q}}`, params.Query)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	// a hasher mapping every query to the same hash must not make
+	// distinct queries share a call
+	client := NewClient(srv.URL, CoalesceRequests(), WithHasher(func([]byte) string {
+		return "x"
+	}))
+
+	queries := []string{"query { secretA }", "query { other }"}
+	responses := make([]struct{ Query string }, len(queries))
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := client.Run(ctx, NewRequest(queries[i]), &responses[i])
+			is.NoErr(err)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	is.Equal(atomic.LoadInt64(&calls), int64(2))
+	for i, response := range responses {
+		is.Equal(response.Query, queries[i])
+	}
+}
+
+type tenantKey struct{}
+
+func TestCoalesceRequestsPropagator(t *testing.T) {
+	is := is.New(t)
+
+	var calls int64
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		atomic.AddInt64(&calls, 1)
+		<-release
+		fmt.Fprintf(w, `{"data":{"tenant":%q}}`, r.Header.Get("X-Tenant"))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, CoalesceRequests(), PreserveResponseBody(), WithPropagator(func(ctx context.Context, h http.Header) {
+		h.Set("X-Tenant", ctx.Value(tenantKey{}).(string))
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	tenants := []string{"a", "b", "a"}
+	responses := make([]struct{ Tenant string }, len(tenants))
+	bodies := make([]string, len(tenants))
+	var wg sync.WaitGroup
+	for i := range tenants {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := client.Run(context.WithValue(ctx, tenantKey{}, tenants[i]), NewRequest(`query { tenant }`), &responses[i])
+			is.NoErr(err)
+			body, err := io.ReadAll(res.Body)
+			is.NoErr(err)
+			bodies[i] = string(body)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	is.Equal(atomic.LoadInt64(&calls), int64(2)) // one call per tenant
+	for i, response := range responses {
+		is.Equal(response.Tenant, tenants[i])
+		// every run reads the whole body of its own copy
+		is.Equal(bodies[i], fmt.Sprintf(`{"data":{"tenant":%q}}`, tenants[i]))
+	}
+}
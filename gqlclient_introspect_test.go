@@ -0,0 +1,70 @@
+package gqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestIntrospect(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var params struct {
+			Query string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&params))
+		is.Equal(params.Query, IntrospectionQuery)
+		io.WriteString(w, `{"data": {"__schema": {
+			"queryType": {"name": "Query"},
+			"mutationType": null,
+			"subscriptionType": null,
+			"types": [
+				{"kind": "OBJECT", "name": "Query", "fields": [{
+					"name": "users",
+					"args": [{"name": "role", "type": {"kind": "ENUM", "name": "Role", "ofType": null}, "defaultValue": "ADMIN"}],
+					"type": {"kind": "NON_NULL", "name": null, "ofType": {"kind": "LIST", "name": null, "ofType": {"kind": "NON_NULL", "name": null, "ofType": {"kind": "OBJECT", "name": "User", "ofType": null}}}},
+					"isDeprecated": false,
+					"deprecationReason": null
+				}]},
+				{"kind": "ENUM", "name": "Role", "enumValues": [
+					{"name": "ADMIN", "isDeprecated": false},
+					{"name": "ROOT", "isDeprecated": true, "deprecationReason": "use ADMIN"}
+				]}
+			],
+			"directives": [{"name": "skip", "locations": ["FIELD"], "args": [{"name": "if", "type": {"kind": "NON_NULL", "name": null, "ofType": {"kind": "SCALAR", "name": "Boolean", "ofType": null}}, "defaultValue": null}]}]
+		}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	schema, err := NewClient(srv.URL).Introspect(ctx)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+	is.Equal(schema.QueryType.Name, "Query")
+	is.Equal(schema.MutationType, nil)
+
+	users := schema.Type("Query").Fields[0]
+	is.Equal(users.Type.String(), "[User!]!")
+	is.Equal(users.Args[0].Type.String(), "Role")
+	is.Equal(*users.Args[0].DefaultValue, "ADMIN")
+
+	role := schema.Type("Role")
+	is.Equal(role.Kind, "ENUM")
+	is.Equal(len(role.EnumValues), 2)
+	is.Equal(role.EnumValues[1].DeprecationReason, "use ADMIN")
+	is.Equal(schema.Type("Missing"), nil)
+
+	is.Equal(schema.Directives[0].Args[0].Type.String(), "Boolean!")
+	is.Equal(schema.Directives[0].Args[0].DefaultValue, nil)
+}
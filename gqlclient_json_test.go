@@ -1,10 +1,21 @@
 package gqlclient
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -40,6 +51,209 @@ func TestDoJSON(t *testing.T) {
 	is.Equal(responseData["something"], "yes")
 }
 
+func TestClose(t *testing.T) {
+	is := is.New(t)
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL)
+
+	errs := make(chan error)
+	go func() {
+		_, err := client.Run(ctx, NewRequest("query {}"), nil)
+		errs <- err
+	}()
+	<-started
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Close()
+		}()
+	}
+	wg.Wait()
+	is.Equal(<-errs, ErrClientClosed)
+	is.True(ctx.Err() == nil) // cancelled before the deadline
+
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err, ErrClientClosed)
+}
+
+func TestCapitalizedEnvelope(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"Data": {"FieldName": "yes"}, "Errors": [{"message": "partial failure"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var responseData struct {
+		FieldName string
+	}
+	var gqlErr GraphQLError
+	_, err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), &responseData)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "partial failure")
+	is.Equal(gqlErr.IsRequestError, false)
+	is.Equal(responseData.FieldName, "yes")
+
+	responseData.FieldName = ""
+	_, err = NewClient(srv.URL, StreamDecode()).Run(ctx, NewRequest("query {}"), &responseData)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(responseData.FieldName, "yes")
+
+	responseData.FieldName = ""
+	_, err = NewClient(srv.URL, StrictEnvelope()).Run(ctx, NewRequest("query {}"), &responseData)
+	is.NoErr(err)
+	is.Equal(responseData.FieldName, "")
+}
+
+func TestStrictEnvelope(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data": {"something": "yes"}, "errors": [{"message": "partial failure"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var responseData map[string]interface{}
+	var gqlErr GraphQLError
+	_, err := NewClient(srv.URL, StrictEnvelope()).Run(ctx, NewRequest("query {}"), &responseData)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "partial failure")
+	is.Equal(responseData["something"], "yes")
+}
+
+func TestAmbiguousOperation(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL)
+
+	doc := `query A { a } query B { b }`
+	_, err := client.Run(ctx, NewRequest(doc), nil)
+	is.Equal(err, ErrAmbiguousOperation)
+	is.Equal(calls, 0)
+
+	_, err = client.Run(ctx, NewRequest(doc).WithOperationName("B"), nil)
+	is.NoErr(err)
+	_, err = client.Run(ctx, NewRequest(`fragment F on Query { a } query A { ...F }`), nil)
+	is.NoErr(err)
+	is.Equal(calls, 2)
+}
+
+func TestPreserveResponseBody(t *testing.T) {
+	is := is.New(t)
+	var response string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, response)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL, PreserveResponseBody(), StreamDecode())
+
+	response = `{"data": {"something": "yes"}}`
+	var responseData map[string]interface{}
+	res, err := client.Run(ctx, NewRequest("query {}"), &responseData)
+	is.NoErr(err)
+	is.Equal(responseData["something"], "yes")
+	b, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	is.Equal(string(b), response)
+
+	response = `{"errors": [{"message": "bad query"}]}`
+	res, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(err != nil)
+	b, err = io.ReadAll(res.Body)
+	is.NoErr(err)
+	is.Equal(string(b), response)
+}
+
+func TestRedirectResendsBody(t *testing.T) {
+	is := is.New(t)
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		bodies = append(bodies, string(b))
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/graphql", http.StatusTemporaryRedirect)
+			return
+		}
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("query ($id: ID!) { user(id: $id) { name } }").WithVar("id", "1")
+	_, err := NewClient(srv.URL+"/old").Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(len(bodies), 2)
+	is.Equal(bodies[0], `{"query":"query ($id: ID!) { user(id: $id) { name } }","variables":{"id":"1"}}`+"\n")
+	is.Equal(bodies[1], bodies[0]) // byte-identical
+}
+
+func TestStreamDecode(t *testing.T) {
+	is := is.New(t)
+	var response string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, response)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL, StreamDecode())
+
+	response = `{"data": {"something": "yes"}}`
+	var responseData map[string]interface{}
+	stats, err := client.RunWithStats(ctx, NewRequest("query {}"), &responseData)
+	is.NoErr(err)
+	is.Equal(responseData["something"], "yes")
+	is.Equal(stats.ResponseBytes, int64(len(response)))
+
+	var gqlErr GraphQLError
+	response = `{"data": null, "errors": [{"message": "resolver failed"}]}`
+	responseData = map[string]interface{}{"kept": true}
+	_, err = client.Run(ctx, NewRequest("query {}"), &responseData)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "resolver failed")
+	is.Equal(gqlErr.IsRequestError, false)
+	is.Equal(responseData["kept"], true)
+
+	response = `{"errors": [{"message": "bad query"}]}`
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.IsRequestError, true)
+
+	response = `{"data": `
+	_, err = client.Run(ctx, NewRequest("query {}"), &responseData)
+	is.True(err != nil)
+}
+
 func TestDoJSONServerError(t *testing.T) {
 	is := is.New(t)
 	var calls int
@@ -65,6 +279,46 @@ func TestDoJSONServerError(t *testing.T) {
 	is.Equal(err.Error(), "graphql: server returned a non-200 status code: 500")
 }
 
+func TestDoJSONStatusClassifier(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `Accepted`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(strings.HasPrefix(err.Error(), "decoding response"))
+
+	client = NewClient(srv.URL, WithStatusClassifier(func(statusCode int) bool {
+		return statusCode != http.StatusOK
+	}))
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: server returned a non-200 status code: 202")
+}
+
+func TestDoJSONSuccessStatus(t *testing.T) {
+	is := is.New(t)
+	for _, status := range []int{http.StatusCreated, http.StatusAccepted} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			io.WriteString(w, `{"data":{"something":"yes"}}`)
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		var responseData map[string]interface{}
+		_, err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), &responseData)
+		is.NoErr(err)
+		is.Equal(responseData["something"], "yes")
+		cancel()
+		srv.Close()
+	}
+}
+
 func TestDoJSONBadRequestErr(t *testing.T) {
 	is := is.New(t)
 	var calls int
@@ -94,140 +348,1617 @@ func TestDoJSONBadRequestErr(t *testing.T) {
 	is.Equal(err.Error(), "graphql: miscellaneous message as to why the the request was bad")
 }
 
-func TestQueryJSON(t *testing.T) {
+func TestDoJSONRequestError(t *testing.T) {
 	is := is.New(t)
-
-	var calls int
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		calls++
-		b, err := io.ReadAll(r.Body)
-		is.NoErr(err)
-		is.Equal(string(b), `{"query":"query {}","variables":null}`+"\n")
-		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
-		is.NoErr(err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"errors": [{"message": "Cannot query field \"nope\""}]}`)
 	}))
 	defer srv.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
-
 	client := NewClient(srv.URL)
+	_, err := client.Run(ctx, NewRequest("query { nope }"), nil)
+	var gqlErr GraphQLError
+	is.True(errors.As(err, &gqlErr))
+	is.True(gqlErr.IsRequestError)
+}
 
-	req := NewRequest("query {}")
+func TestDoJSONExecutionError(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"data": {"something": "yes", "broken": null},
+			"errors": [{"message": "broken resolver"}]
+		}`)
+	}))
+	defer srv.Close()
 
-	// check variables
-	is.True(req != nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL)
+	var responseData map[string]interface{}
+	_, err := client.Run(ctx, NewRequest("query {}"), &responseData)
+	var gqlErr GraphQLError
+	is.True(errors.As(err, &gqlErr))
+	is.True(!gqlErr.IsRequestError)
+	is.Equal(responseData["something"], "yes") // partial data is kept
+}
 
-	var resp struct {
-		Value string
-	}
-	_, err := client.Run(ctx, req, &resp)
-	is.NoErr(err)
-	is.Equal(calls, 1)
+func TestDoJSONErrorsWithoutData(t *testing.T) {
+	is := is.New(t)
+	for _, body := range []string{
+		`{"errors": [{"message": "not authorized"}]}`,
+		`{"data": null, "errors": [{"message": "not authorized"}]}`,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, body)
+		}))
 
-	is.Equal(resp.Value, "some data")
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		client := NewClient(srv.URL)
+		resp := struct {
+			Value string
+		}{
+			Value: "from a previous run",
+		}
+		_, err := client.Run(ctx, NewRequest("query {}"), &resp)
+		is.Equal(err.Error(), "graphql: not authorized")
+		is.Equal(resp.Value, "from a previous run") // resp is left untouched
+		cancel()
+		srv.Close()
+	}
 }
 
-func TestQueryJSONWithVars(t *testing.T) {
+func TestDoJSONErrorRequestID(t *testing.T) {
 	is := is.New(t)
-
-	var calls int
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		calls++
-		b, err := io.ReadAll(r.Body)
-		is.NoErr(err)
-		is.Equal(string(b), `{"query":"query {}","variables":{"username":"lelebus"}}`+"\n")
-		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
-		is.NoErr(err)
+		io.WriteString(w, `{"errors": [{
+			"message": "internal error",
+			"extensions": {"code": "INTERNAL", "requestId": "abc-123", "traceId": 42}
+		}]}`)
 	}))
 	defer srv.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
-	client := NewClient(srv.URL)
-
-	req := NewRequest("query {}").WithVars(map[string]interface{}{
-		"username": "lelebus",
-	})
-
-	// check variables
-	is.True(req != nil)
-	is.Equal(req.variables["username"], "lelebus")
+	var gqlErr GraphQLError
+	_, err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Extensions["code"], "INTERNAL")
+	is.Equal(gqlErr.RequestID(), "abc-123")
 
-	var resp struct {
-		Value string
-	}
-	_, err := client.Run(ctx, req, &resp)
-	is.NoErr(err)
-	is.Equal(calls, 1)
+	_, err = NewClient(srv.URL, WithRequestIDKey("traceId")).Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.RequestID(), "42")
 
-	is.Equal(resp.Value, "some data")
+	_, err = NewClient(srv.URL, WithRequestIDKey("missing")).Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.RequestID(), "")
 }
 
-func TestHeader(t *testing.T) {
+func TestAcceptHeader(t *testing.T) {
 	is := is.New(t)
-
-	var calls int
+	var accept []string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		calls++
-		is.Equal(r.Header.Get("X-Custom-Header"), "123")
-
-		_, err := io.WriteString(w, `{"data":{"value":"some data"}}`)
-		is.NoErr(err)
+		accept = r.Header.Values("Accept")
+		io.WriteString(w, `{"data":{}}`)
 	}))
 	defer srv.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
-	client := NewClient(srv.URL)
+	_, err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(accept, []string{"application/json; charset=utf-8"})
 
 	req := NewRequest("query {}")
-	req.Header.Set("X-Custom-Header", "123")
+	req.Header.Set("Accept", "application/graphql-response+json")
+	_, err = NewClient(srv.URL).Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(accept, []string{"application/graphql-response+json"})
 
-	var resp struct {
-		Value string
-	}
-	_, err := client.Run(ctx, req, &resp)
+	_, err = NewClient(srv.URL, OmitAcceptHeader()).Run(ctx, NewRequest("query {}"), nil)
 	is.NoErr(err)
-	is.Equal(calls, 1)
+	is.Equal(len(accept), 0)
 
-	is.Equal(resp.Value, "some data")
+	_, err = NewClient(srv.URL, OmitAcceptHeader()).Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(accept, []string{"application/graphql-response+json"})
 }
 
-func TestResponseWithCookies(t *testing.T) {
+func TestUserAgent(t *testing.T) {
 	is := is.New(t)
-
-	var calls int
+	var userAgent []string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		calls++
+		userAgent = r.Header.Values("User-Agent")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
 
-		http.SetCookie(w, &http.Cookie{
-			Name:  "cookie1",
-			Value: "value1",
-		})
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL, WithUserAgent("billing-service/1.4"))
 
-		_, err := io.WriteString(w, `{"data":{"value":"some data"}}`)
-		is.NoErr(err)
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(userAgent, []string{"billing-service/1.4"})
+
+	req := NewRequest("query {}")
+	req.Header.Set("User-Agent", "billing-cron/1.0")
+	_, err = client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(userAgent, []string{"billing-cron/1.0"})
+}
+
+func TestLanguage(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages := map[string][]string{
+			"it-IT": {"campo sconosciuto", "accesso negato"},
+			"de-DE": {"unbekanntes Feld", "Zugriff verweigert"},
+		}[r.Header.Get("Accept-Language")]
+		is.Equal(len(messages), 2)
+		b, _ := json.Marshal(map[string]interface{}{"errors": []map[string]string{
+			{"message": messages[0]},
+			{"message": messages[1]},
+		}})
+		w.Write(b)
 	}))
 	defer srv.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
+	client := NewClient(srv.URL, WithLanguage("it-IT"))
 
-	client := NewClient(srv.URL)
+	var gqlErr GraphQLError
+	stats, err := client.RunWithStats(ctx, NewRequest("query {}"), nil)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "campo sconosciuto")
+	is.Equal(len(stats.Errors), 2)
+	is.Equal(stats.Errors[1].Message, "accesso negato")
 
 	req := NewRequest("query {}")
+	req.Header.Set("Accept-Language", "de-DE")
+	_, err = client.Run(ctx, req, nil)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "unbekanntes Feld")
+}
 
-	var resp struct {
-		Value string
-	}
-	res, err := client.Run(ctx, req, &resp)
-	is.NoErr(err)
-	is.Equal(calls, 1)
+func TestNonStringErrorMessage(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data": {"something": "yes"}, "errors": [
+			{"message": {"code": 42, "text": "upstream failed"}, "extensions": {"code": "UPSTREAM"}},
+			{"message": ["a", "b"]},
+			{"message": null},
+			{"message": "plain"}
+		]}`)
+	}))
+	defer srv.Close()
 
-	is.Equal(resp.Value, "some data")
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
 
-	cookies := res.Cookies()
-	is.Equal(len(cookies), 1)
-	is.Equal(cookies[0].Name, "cookie1")
-	is.Equal(cookies[0].Value, "value1")
+	var responseData map[string]interface{}
+	stats, err := NewClient(srv.URL).RunWithStats(ctx, NewRequest("query {}"), &responseData)
+	var gqlErr GraphQLError
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, `{"code":42,"text":"upstream failed"}`)
+	is.Equal(gqlErr.Extensions["code"], "UPSTREAM")
+	is.Equal(responseData["something"], "yes")
+	is.Equal(len(stats.Errors), 4)
+	is.Equal(stats.Errors[1].Message, `["a","b"]`)
+	is.Equal(stats.Errors[2].Message, "")
+	is.Equal(stats.Errors[3].Message, "plain")
+}
+
+func TestDecodeErrorBody(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html>token=abc123 `+strings.Repeat("x", 300)+`</html>`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
 
+	_, err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	var decodeErr *DecodeError
+	is.True(errors.As(err, &decodeErr))
+	is.Equal(len(decodeErr.Body), 256+len("..."))
+	is.True(strings.HasPrefix(decodeErr.Body, "<html>token=abc123 xxx"))
+	is.True(strings.HasPrefix(err.Error(), `decoding response: invalid character '<' looking for beginning of value (body: "<html>token=abc123 xxx`))
+	var syntaxErr *json.SyntaxError
+	is.True(errors.As(err, &syntaxErr))
+
+	client := NewClient(srv.URL, WithBodySnippet(18, func(body []byte) []byte {
+		return bytes.ReplaceAll(body, []byte("abc123"), []byte("***"))
+	}))
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.As(err, &decodeErr))
+	is.Equal(decodeErr.Body, "<html>token=***...")
+
+	_, err = NewClient(srv.URL, WithBodySnippet(-1, nil)).Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "decoding response: invalid character '<' looking for beginning of value")
+}
+
+func TestExpectContentType(t *testing.T) {
+	is := is.New(t)
+	contentType := "text/html"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, `<html>`+strings.Repeat("x", 300)+`</html>`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, ExpectContentType("application/json"))
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.Is(err, ErrUnexpectedContentType))
+	var typeErr *ContentTypeError
+	is.True(errors.As(err, &typeErr))
+	is.Equal(typeErr.Got, "text/html")
+	is.Equal(typeErr.Body, `<html>`+strings.Repeat("x", 250)+"...")
+	is.True(strings.HasPrefix(err.Error(), `graphql: unexpected content type: got text/html, expected application/json (body: "<html>xxx`))
+
+	contentType = "Application/JSON; charset=utf-8"
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	var decodeErr *DecodeError
+	is.True(errors.As(err, &decodeErr)) // content type matches, body does not
+}
+
+func TestPayloadTooLarge(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		io.WriteString(w, `<html>413 Request Entity Too Large</html>`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	_, err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.Is(err, ErrPayloadTooLarge))
+	var tooLarge *PayloadTooLargeError
+	is.True(errors.As(err, &tooLarge))
+	is.Equal(tooLarge.BodySize, int64(len(`{"query":"query {}","variables":null}`+"\n")))
+	is.Equal(tooLarge.RetryAfter, 30*time.Second)
+	is.Equal(err.Error(), "graphql: payload too large: request body of 38 bytes (retry after 30s)")
+}
+
+func TestMultipleErrors(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"errors": [{"message": "first"}, {"message": "second"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	_, err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: first\ngraphql: second")
+	var gqlErrs GraphQLErrors
+	is.True(errors.As(err, &gqlErrs))
+	is.Equal(len(gqlErrs), 2)
+	var gqlErr GraphQLError
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "first")
+
+	errJoined := errors.New("joined")
+	client := NewClient(srv.URL, WithErrorJoiner(func(errs []GraphQLError) error {
+		is.Equal(len(errs), 2)
+		return fmt.Errorf("%w: %d errors: %s; %s", errJoined, len(errs), errs[0].Message, errs[1].Message)
+	}))
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "joined: 2 errors: first; second")
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "first")
+	is.True(errors.Is(err, errJoined))
+}
+
+func TestMinimalResponses(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Prefer"), "return=minimal")
+		io.WriteString(w, `{"errors": [{
+			"message": "internal error",
+			"extensions": {"requestId": "abc-123"}
+		}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var gqlErr GraphQLError
+	_, err := NewClient(srv.URL, MinimalResponses()).Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "internal error")
+	is.Equal(gqlErr.Extensions, nil)
+	is.Equal(calls, 1)
+}
+
+func TestResponseValidator(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data": {"value": 42}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	errNotString := errors.New("value is not a string")
+	client := NewClient(srv.URL, WithResponseValidator(func(data json.RawMessage) error {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		if _, ok := fields["value"].(string); !ok {
+			return errNotString
+		}
+		return nil
+	}))
+	var resp map[string]interface{}
+	_, err := client.Run(ctx, NewRequest("query {}"), &resp)
+	var validationErr *ValidationError
+	is.True(errors.As(err, &validationErr))
+	is.True(errors.Is(err, errNotString))
+	is.Equal(err.Error(), "graphql: invalid response data: value is not a string")
+	is.Equal(resp, nil) // not decoded
+}
+
+func TestDoJSONErrorExtractor(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"error": "gateway says no"}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithErrorExtractor(func(body []byte) ([]GraphQLError, error) {
+		var envelope struct {
+			Error string
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return nil, err
+		}
+		if envelope.Error == "" {
+			return nil, nil
+		}
+		return []GraphQLError{{Message: envelope.Error}}, nil
+	}))
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: gateway says no")
+}
+
+func TestQueryJSON(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":null}`+"\n")
+		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	req := NewRequest("query {}")
+
+	// check variables
+	is.True(req != nil)
+
+	var resp struct {
+		Value string
+	}
+	_, err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+
+	is.Equal(resp.Value, "some data")
+}
+
+func TestQueryJSONWithVars(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":{"username":"lelebus"}}`+"\n")
+		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	req := NewRequest("query {}").WithVars(map[string]interface{}{
+		"username": "lelebus",
+	})
+
+	// check variables
+	is.True(req != nil)
+	is.Equal(req.variables["username"], "lelebus")
+
+	var resp struct {
+		Value string
+	}
+	_, err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+
+	is.Equal(resp.Value, "some data")
+}
+
+func TestQueryJSONWithVar(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":{"limit":10,"username":"lelebus"}}`+"\n")
+		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	vars := map[string]interface{}{
+		"username": "lelebus",
+	}
+	req := NewRequest("query {}").WithVars(vars).WithVar("limit", 10)
+	is.Equal(len(vars), 1) // the map passed to WithVars is not modified
+
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestQueryJSONWithNullVar(t *testing.T) {
+	is := is.New(t)
+
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		bodies = append(bodies, string(b))
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	for _, req := range []*Request{
+		NewRequest("query {}").WithVar("x", nil).WithVar("y", 1),
+		NewRequest("query {}").WithVars(Vars{}.Set("x", nil).SetInt("y", 1)),
+		NewRequest("query {}").WithVar("y", 1),
+	} {
+		_, err := client.Run(ctx, req, nil)
+		is.NoErr(err)
+	}
+	is.Equal(bodies[0], `{"query":"query {}","variables":{"x":null,"y":1}}`+"\n") // explicit null
+	is.Equal(bodies[1], `{"query":"query {}","variables":{"x":null,"y":1}}`+"\n") // explicit null
+	is.Equal(bodies[2], `{"query":"query {}","variables":{"y":1}}`+"\n")          // omitted
+}
+
+func TestStrictVars(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	req := NewRequest("query {}").StrictVars().WithVars(map[string]interface{}{
+		"username": "lelebus",
+	}).WithVar("limit", 10)
+	is.NoErr(req.err)
+
+	req.WithVar("username", "someone else")
+	_, err := client.Run(ctx, req, nil)
+	is.Equal(err.Error(), `graphql: variable "username" assigned more than once`)
+	is.Equal(calls, 0)
+}
+
+func TestQueryJSONWithOperationName(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query A { a } query B { b }","variables":null,"operationName":"B"}`+"\n")
+		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	req := NewRequest("query A { a } query B { b }").WithOperationName("B")
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestRawBody(t *testing.T) {
+	is := is.New(t)
+
+	body := `{"query":"query ($id: ID!) { user(id: $id) { name } }","variables":{"id":"1"}}`
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Content-Type"), "application/json")
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), body)
+		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	var resp struct {
+		Value string
+	}
+	_, err := client.Run(ctx, NewRequest("").WithRawBody([]byte(body), "application/json"), &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+	is.Equal(resp.Value, "some data")
+
+	req := NewRequest("").WithRawBody([]byte(body), "application/json").WithVar("id", "2")
+	_, err = client.Run(ctx, req, &resp)
+	is.Equal(err.Error(), "graphql: cannot send a raw body with variables or files")
+	is.Equal(calls, 1)
+}
+
+func TestRunFull(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data"},"meta":{"cursor":"abc"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	var resp struct {
+		Value string
+	}
+	var envelope map[string]json.RawMessage
+	_, err := client.RunFull(ctx, NewRequest("query {}"), &resp, &envelope)
+	is.NoErr(err)
+	is.Equal(resp.Value, "some data")
+	is.Equal(len(envelope), 1) // all but data
+	is.Equal(string(envelope["meta"]), `{"cursor":"abc"}`)
+}
+
+func TestHeader(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("X-Custom-Header"), "123")
+
+		_, err := io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	req := NewRequest("query {}")
+	req.Header.Set("X-Custom-Header", "123")
+
+	var resp struct {
+		Value string
+	}
+	_, err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+
+	is.Equal(resp.Value, "some data")
+}
+
+func TestBasicAuth(t *testing.T) {
+	is := is.New(t)
+
+	var authorizations []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithBasicAuth("lelebus", "secret"))
+
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	req := NewRequest("query {}")
+	req.Header.Set("Authorization", "Bearer token")
+	_, err = client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(authorizations, []string{"Basic bGVsZWJ1czpzZWNyZXQ=", "Bearer token"})
+}
+
+func TestPropagator(t *testing.T) {
+	is := is.New(t)
+
+	type traceKey struct{}
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("X-Trace-Id"), "trace-1")
+		is.Equal(r.Header.Get("X-Custom-Header"), "overridden")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithPropagator(func(ctx context.Context, h http.Header) {
+		h.Set("X-Trace-Id", ctx.Value(traceKey{}).(string))
+		h.Set("X-Custom-Header", "overridden")
+	}))
+
+	req := NewRequest("query {}")
+	req.Header.Set("X-Custom-Header", "123")
+	_, err := client.Run(context.WithValue(ctx, traceKey{}, "trace-1"), req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestResponseWithCookies(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		http.SetCookie(w, &http.Cookie{
+			Name:  "cookie1",
+			Value: "value1",
+		})
+
+		_, err := io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	req := NewRequest("query {}")
+
+	var resp struct {
+		Value string
+	}
+	res, err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+
+	is.Equal(resp.Value, "some data")
+
+	cookies := res.Cookies()
+	is.Equal(len(cookies), 1)
+	is.Equal(cookies[0].Name, "cookie1")
+	is.Equal(cookies[0].Value, "value1")
+
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	is := is.New(t)
+
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			prev := atomic.LoadInt32(&maxInFlight)
+			if n <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithMaxConcurrency(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Run(ctx, NewRequest("query {}"), nil)
+			is.NoErr(err)
+		}()
+	}
+	wg.Wait()
+	is.True(atomic.LoadInt32(&maxInFlight) <= 2)
+}
+
+func TestMaxConcurrencyCancelled(t *testing.T) {
+	is := is.New(t)
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := NewClient(srv.URL, WithMaxConcurrency(1))
+	go client.Run(context.Background(), NewRequest("query {}"), nil)
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err, context.DeadlineExceeded)
+}
+
+func TestUseApplicationGraphQL(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Content-Type"), "application/graphql; charset=utf-8")
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `query {}`)
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseApplicationGraphQL())
+
+	var resp struct {
+		Value string
+	}
+	_, err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+	is.Equal(resp.Value, "some data")
+}
+
+func TestUseApplicationGraphQLWithVars(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Content-Type"), "application/json; charset=utf-8")
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":{"username":"lelebus"}}`+"\n")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseApplicationGraphQL())
+
+	req := NewRequest("query {}").WithVars(map[string]interface{}{
+		"username": "lelebus",
+	})
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestUseApplicationGraphQLWithOperationName(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Content-Type"), "application/json; charset=utf-8")
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query A { a } query B { b }","variables":null,"operationName":"B"}`+"\n")
+		io.WriteString(w, `{"data":{"b":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var logs []string
+	client := NewClient(srv.URL, UseApplicationGraphQL())
+	client.Log = func(s string) { logs = append(logs, s) }
+
+	_, err := client.Run(ctx, NewRequest("query A { a } query B { b }").WithOperationName("B"), nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+	is.True(strings.Contains(strings.Join(logs, "\n"), "application/graphql cannot carry an operation name"))
+}
+
+func TestNotModified(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("If-Modified-Since"), "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	req := NewRequest("query {}")
+	req.Header.Set("If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT")
+	resp := struct {
+		Value string
+	}{
+		Value: "cached",
+	}
+	res, err := client.Run(ctx, req, &resp)
+	is.Equal(err, ErrNotModified)
+	is.Equal(res.StatusCode, http.StatusNotModified)
+	is.Equal(calls, 1)
+	is.Equal(resp.Value, "cached")
+}
+
+func TestQueryJSONUnmarshaler(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"node":{"__typename":"User","name":"lelebus"}}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	var resp nodeResponse
+	_, err := client.Run(ctx, NewRequest("query { node { __typename } }"), &resp)
+	is.NoErr(err)
+	user, ok := resp.Node.(*testUser)
+	is.True(ok) // node is decoded by its __typename
+	is.Equal(user.Name, "lelebus")
+}
+
+// nodeResponse decodes its node polymorphically, based on __typename.
+type nodeResponse struct {
+	Node interface{}
+}
+
+type testUser struct {
+	Name string
+}
+
+type testRepo struct {
+	Stars int
+}
+
+func (r *nodeResponse) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Node json.RawMessage
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var typename struct {
+		Typename string `json:"__typename"`
+	}
+	if err := json.Unmarshal(raw.Node, &typename); err != nil {
+		return err
+	}
+	switch typename.Typename {
+	case "User":
+		r.Node = &testUser{}
+	case "Repository":
+		r.Node = &testRepo{}
+	default:
+		return errors.New("unknown type " + typename.Typename)
+	}
+	return json.Unmarshal(raw.Node, r.Node)
+}
+
+func TestEndpointRouter(t *testing.T) {
+	is := is.New(t)
+
+	var reads, writes int
+	read := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer read.Close()
+	write := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writes++
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer write.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(read.URL, WithEndpointRouter(func(req *Request) string {
+		if req.OperationType() == "mutation" {
+			return write.URL
+		}
+		return ""
+	}))
+
+	_, err := client.Run(ctx, NewRequest("query { a }"), nil)
+	is.NoErr(err)
+	_, err = client.Run(ctx, NewRequest("mutation { b }"), nil)
+	is.NoErr(err)
+	is.Equal(reads, 1)
+	is.Equal(writes, 1)
+}
+
+func TestResponseProgress(t *testing.T) {
+	is := is.New(t)
+
+	body := `{"data":{"value":"` + strings.Repeat("x", 100000) + `"}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var calls int
+	var lastRead, lastTotal int64
+	client := NewClient(srv.URL, WithResponseProgress(func(bytesRead, total int64) {
+		calls++
+		is.True(bytesRead > lastRead)
+		lastRead, lastTotal = bytesRead, total
+	}))
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.True(calls > 1) // reported as the body streams in
+	is.Equal(lastRead, int64(len(body)))
+	is.Equal(lastTotal, int64(len(body)))
+}
+
+func BenchmarkRun(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp struct {
+			Value string
+		}
+		if _, err := client.Run(ctx, req, &resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEndpointRedirect(t *testing.T) {
+	is := is.New(t)
+
+	var newCalls int32
+	newSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&newCalls, 1)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer newSrv.Close()
+	var oldCalls int32
+	oldSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&oldCalls, 1)
+		w.Header().Set("X-GraphQL-Endpoint", newSrv.URL)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer oldSrv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(oldSrv.URL, WithEndpointRedirect("X-GraphQL-Endpoint"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Run(ctx, NewRequest("query {}"), nil)
+			is.NoErr(err)
+		}()
+	}
+	wg.Wait()
+	is.True(atomic.LoadInt32(&oldCalls) >= 1)
+
+	atomic.StoreInt32(&oldCalls, 0)
+	atomic.StoreInt32(&newCalls, 0)
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(atomic.LoadInt32(&oldCalls), int32(0))
+	is.Equal(atomic.LoadInt32(&newCalls), int32(1))
+}
+
+func TestAsyncLog(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, AsyncLog(2))
+	release := make(chan struct{})
+	logged := make(chan string, 100)
+	client.Log = func(s string) {
+		<-release // a stuck log sink
+		logged <- s
+	}
+
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)                     // the request completes despite the stuck sink
+	is.True(client.DroppedLogs() > 0) // messages beyond the buffer are dropped
+	close(release)
+	is.Equal(<-logged, ">> operation: anonymous")
+}
+
+func TestAsyncLogClose(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var logged []string
+	client := NewClient(srv.URL, AsyncLog(100))
+	client.Log = func(s string) {
+		time.Sleep(time.Millisecond) // a slow log sink
+		logged = append(logged, s)
+	}
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	client.Close() // flushes the queue
+	is.True(len(logged) > 1)
+	is.Equal(logged[0], ">> operation: anonymous")
+	is.Equal(client.DroppedLogs(), int64(0))
+	client.Close()
+
+	// AsyncLog(0) keeps one message
+	client = NewClient(srv.URL, AsyncLog(0))
+	client.logf("message")
+	is.Equal(client.DroppedLogs(), int64(0))
+	client.Close()
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		NewClient(srv.URL, AsyncLog(10)).Close()
+	}
+	is.True(runtime.NumGoroutine() <= before+5) // the delivering goroutines exited
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, WithDefaultTimeout(20*time.Millisecond))
+
+	_, err := client.Run(context.Background(), NewRequest("query {}"), nil)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+
+	// an existing deadline is left untouched, even if it is longer
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithResponseHeaderTimeout(20*time.Millisecond))
+	is.True(client.HttpClient() != http.DefaultClient) // the client owns its transport
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "timeout awaiting response headers"))
+
+	var logs []string
+	client = NewClient(srv.URL, WithHTTPClient(&http.Client{}), WithResponseHeaderTimeout(20*time.Millisecond))
+	client.Log = func(s string) { logs = append(logs, s) }
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(logs[0], "transport options are ignored with a custom http.Client")
+}
+
+func TestTransport(t *testing.T) {
+	is := is.New(t)
+
+	var closed []bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		closed = append(closed, r.Close)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var calls int
+	client := NewClient(srv.URL, WithResponseHeaderTimeout(time.Second), WithTransport(func(transport *http.Transport) {
+		calls++
+		is.Equal(transport.ResponseHeaderTimeout, time.Second) // applied in order
+		transport.DisableKeepAlives = true
+	}))
+	for i := 0; i < 2; i++ {
+		_, err := client.Run(ctx, NewRequest("query {}"), nil)
+		is.NoErr(err)
+	}
+	is.Equal(calls, 1)
+	is.Equal(closed, []bool{true, true}) // keep-alives disabled
+
+	var logs []string
+	client = NewClient(srv.URL, WithHTTPClient(&http.Client{}), WithTransport(func(transport *http.Transport) {
+		t.Fatal("configured a custom http.Client")
+	}))
+	client.Log = func(s string) { logs = append(logs, s) }
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(logs[0], "transport options are ignored with a custom http.Client")
+}
+
+func TestProxy(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.URL.String(), "http://graphql.example/graphql")
+		is.Equal(r.Header.Get("Proxy-Authorization"), "Basic "+base64.StdEncoding.EncodeToString([]byte("user:secret")))
+		io.WriteString(w, `{"data":{"something":"yes"}}`)
+	}))
+	defer proxy.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	proxyURL := strings.Replace(proxy.URL, "http://", "http://user:secret@", 1)
+	client := NewClient("http://graphql.example/graphql", WithProxy(proxyURL))
+	var responseData map[string]interface{}
+	_, err := client.Run(ctx, NewRequest("query {}"), &responseData)
+	is.NoErr(err)
+	is.Equal(responseData["something"], "yes")
+	is.Equal(calls, 1)
+
+	client = NewClient("http://graphql.example/graphql", WithProxy("proxy.internal"))
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), `invalid proxy: "proxy.internal" is not an absolute URL`))
+	is.Equal(calls, 1)
+}
+
+func TestEndpointDecorator(t *testing.T) {
+	is := is.New(t)
+
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL+"/graphql", WithEndpointDecorator(func(base string, req *Request) (string, error) {
+		return base + "/" + req.OperationName(), nil
+	}))
+	_, err := client.Run(ctx, NewRequest("query GetUser { user { name } }"), nil)
+	is.NoErr(err)
+	is.Equal(paths, []string{"/graphql/GetUser"})
+
+	client = NewClient(srv.URL, WithEndpointDecorator(func(base string, req *Request) (string, error) {
+		return "/relative", nil
+	}))
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), `decorate endpoint: "/relative" is not an absolute URL`)
+	is.Equal(len(paths), 1)
+}
+
+func TestAuditHook(t *testing.T) {
+	is := is.New(t)
+	var sent bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var recs []AuditRecord
+	client := NewClient(srv.URL, WithAuditHook(func(rec AuditRecord) {
+		is.True(!sent) // called before sending
+		recs = append(recs, rec)
+	}), WithVariableRedactor(func(key string, value interface{}) interface{} {
+		if key == "password" {
+			return "***"
+		}
+		return value
+	}))
+	query := `mutation Login($user: String!, $password: String!) { login(user: $user, password: $password) }`
+	vars := map[string]interface{}{"user": "lelebus", "password": "secret"}
+	before := time.Now()
+	_, err := client.Run(ctx, NewRequest(query).WithVars(vars), nil)
+	is.NoErr(err)
+	is.True(sent)
+
+	is.Equal(len(recs), 1)
+	is.Equal(recs[0].OperationName, "Login")
+	is.Equal(recs[0].Query, query)
+	is.Equal(recs[0].Variables, map[string]interface{}{"user": "lelebus", "password": "***"})
+	is.Equal(recs[0].Endpoint, srv.URL)
+	is.True(!recs[0].Timestamp.Before(before))
+	is.Equal(vars["password"], "secret")
+}
+
+func TestVariableRedactor(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":{"password":"secret","username":"lelebus"}}`+"\n")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithVariableRedactor(func(key string, value interface{}) interface{} {
+		if key == "password" {
+			return "***"
+		}
+		return value
+	}))
+	var logs []string
+	client.Log = func(s string) { logs = append(logs, s) }
+
+	req := NewRequest("query {}").WithVar("username", "lelebus").WithVar("password", "secret")
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(logs[1], ">> variables: map[password:*** username:lelebus]")
+}
+
+func TestDryRun(t *testing.T) {
+	is := is.New(t)
+
+	client := NewClient("http://localhost:1/graphql", DryRun())
+	var logs []string
+	client.Log = func(s string) { logs = append(logs, s) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	resp := map[string]interface{}{"untouched": true}
+	res, err := client.Run(ctx, NewRequest("query GetUser { user { name } }").WithVar("id", 1), &resp)
+	is.NoErr(err)
+	is.Equal(res.StatusCode, http.StatusOK)
+	is.Equal(res.Request.URL.String(), "http://localhost:1/graphql")
+	is.Equal(res.Request.Header.Get("Content-Type"), "application/json; charset=utf-8")
+	is.Equal(resp["untouched"], true)
+	is.Equal(logs[0], ">> operation: GetUser")
+	is.Equal(logs[len(logs)-1], "<< {}")
+}
+
+func TestStub(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"name":"from the server"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL,
+		WithStub("GetUser", map[string]interface{}{"name": "stubbed"}, nil),
+		WithStubMatching("GetRepo", func(variables map[string]interface{}) bool {
+			return variables["id"] == "private"
+		}, nil, []string{"not found"}),
+	)
+
+	var resp struct {
+		Name string
+	}
+	_, err := client.Run(ctx, NewRequest("query GetUser { user { name } }"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Name, "stubbed")
+	is.Equal(calls, 0)
+
+	_, err = client.Run(ctx, NewRequest("query GetRepo($id: ID!) { repo(id: $id) { name } }").WithVar("id", "private"), nil)
+	is.Equal(err.Error(), "graphql: not found")
+	is.Equal(calls, 0)
+
+	_, err = client.Run(ctx, NewRequest("query GetRepo($id: ID!) { repo(id: $id) { name } }").WithVar("id", "public"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Name, "from the server")
+	is.Equal(calls, 1)
+}
+
+func TestResolveConditionalDirectives(t *testing.T) {
+	is := is.New(t)
+
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		queries = append(queries, body.Query)
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	query := `query Q($withDetails: Boolean!) { value details @include(if: $withDetails) { id } }`
+	client := NewClient(srv.URL, ResolveConditionalDirectives())
+	_, err := client.Run(ctx, NewRequest(query), nil)
+	is.NoErr(err)
+	_, err = client.Run(ctx, NewRequest(query).WithVar("withDetails", true), nil)
+	is.NoErr(err)
+	_, err = NewClient(srv.URL).Run(ctx, NewRequest(query), nil)
+	is.NoErr(err)
+	is.Equal(queries, []string{`query Q { value  }`, query, query})
+}
+
+func TestRunWithFactory(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	type result struct {
+		Value string
+	}
+	client := NewClient(srv.URL)
+	data, res, err := client.RunWithFactory(ctx, NewRequest("query {}"), func() interface{} {
+		return &result{}
+	})
+	is.NoErr(err)
+	is.Equal(res.StatusCode, http.StatusOK)
+	is.Equal(data.(*result).Value, "some data")
+
+	data, _, err = client.RunWithFactory(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(data, nil)
+}
+
+func TestResponseTap(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		if body.Query == "query Broken {}" {
+			io.WriteString(w, `{"errors":[{"message":"broken"}]}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	type result struct {
+		Value string
+	}
+	var tapped []string
+	client := NewClient(srv.URL, WithResponseTap(func(req *Request, resp interface{}) {
+		tapped = append(tapped, req.OperationName()+": "+resp.(*result).Value)
+	}))
+	var resp result
+	_, err := client.Run(ctx, NewRequest("query Good {}"), &resp)
+	is.NoErr(err)
+	_, err = client.Run(ctx, NewRequest("query Broken {}"), &resp)
+	is.Equal(err.Error(), "graphql: broken")
+	_, err = client.Run(ctx, NewRequest("query Good {}"), nil)
+	is.NoErr(err)
+	is.Equal(tapped, []string{"Good: some data"})
+}
+
+func TestUseFormURLEncoded(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+		is.NoErr(r.ParseForm())
+		is.Equal(r.PostForm, url.Values{
+			"query":         {"query Q($id: ID!) {}"},
+			"variables":     {`{"id":"42"}`},
+			"operationName": {"Q"},
+			"csrf":          {"token"},
+		})
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseFormURLEncoded())
+	req := NewRequest("query Q($id: ID!) {}").WithVar("id", "42").WithOperationName("Q").WithFormField("csrf", "token")
+	var resp struct {
+		Value string
+	}
+	_, err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "some data")
+
+	req = NewRequest("query {}")
+	req.File("file", "file.txt", strings.NewReader("content"))
+	_, err = client.Run(ctx, req, nil)
+	is.Equal(err.Error(), "cannot send files with PostFields option")
+}
+
+func TestIncludeRequestInErrors(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"errors":[{"message":"broken"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, IncludeRequestInErrors(), WithBasicAuth("user", "secret"))
+	req := NewRequest("query {}").WithVar("id", "42")
+	req.Header.Set("X-Tenant", "acme")
+	_, err := client.Run(ctx, req, nil)
+	is.Equal(err.Error(), `graphql: broken (request body: {"query":"query {}","variables":{"id":"42"}})`)
+	var reqErr *RequestError
+	is.True(errors.As(err, &reqErr))
+	is.Equal(reqErr.Header.Get("Authorization"), "[redacted]")
+	is.Equal(reqErr.Header.Get("X-Tenant"), "acme")
+	var gqlErr GraphQLError
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "broken")
+
+	_, err = NewClient(srv.URL).Run(ctx, req, nil)
+	is.Equal(err.Error(), "graphql: broken")
+}
+
+// blockingTransport answers with the start of a response, then blocks
+// until the body is closed, whatever the context of the request.
+func blockingTransport(start string) *http.Client {
+	return &http.Client{Transport: &GraphQLRoundTripper{
+		Wrap: func(operationName string, r *http.Request, next http.RoundTripper) (*http.Response, error) {
+			pr, pw := io.Pipe()
+			go io.WriteString(pw, start)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       pr,
+				Request:    r,
+			}, nil
+		},
+	}}
+}
+
+func TestStreamDecodeCancel(t *testing.T) {
+	is := is.New(t)
+
+	client := NewClient("http://graphql.example", StreamDecode(), WithHTTPClient(blockingTransport(`{"data":{"value":"so`)))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var resp struct {
+		Value string
+	}
+	_, err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err, context.DeadlineExceeded)
+	is.Equal(resp.Value, "") // no partial decode
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = client.Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err, context.Canceled)
+}
+
+func TestDecodeTimeout(t *testing.T) {
+	is := is.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	for _, streamed := range []bool{true, false} {
+		opts := []ClientOption{WithDecodeTimeout(20 * time.Millisecond), WithHTTPClient(blockingTransport(`{"data":`))}
+		if streamed {
+			opts = append(opts, StreamDecode())
+		}
+		_, err := NewClient("http://graphql.example", opts...).Run(ctx, NewRequest("query {}"), nil)
+		is.True(errors.Is(err, context.DeadlineExceeded))
+		is.Equal(err.Error(), "graphql: decoding response took longer than 20ms: context deadline exceeded")
+	}
 }
@@ -2,6 +2,8 @@ package gqlclient
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -34,6 +36,36 @@ func TestWithClient(t *testing.T) {
 	is.Equal(calls, 1) // calls
 }
 
+func TestWithBaseContext(t *testing.T) {
+	is := is.New(t)
+	type key string
+	var tenant, user interface{}
+	testClient := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			tenant = req.Context().Value(key("tenant"))
+			user = req.Context().Value(key("user"))
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"data":{"key":"value"}}`)),
+			}
+			return resp, nil
+		}),
+	}
+
+	base, cancelBase := context.WithCancel(context.Background())
+	base = context.WithValue(base, key("tenant"), "default-tenant")
+	base = context.WithValue(base, key("user"), "default-user")
+	cancelBase() // cancelling the base context does not cancel requests
+
+	client := NewClient("", WithHTTPClient(testClient), WithBaseContext(base))
+
+	ctx := context.WithValue(context.Background(), key("user"), "lelebus")
+	_, err := client.Run(ctx, NewRequest(`query {}`), nil)
+	is.NoErr(err)
+	is.Equal(tenant, "default-tenant")
+	is.Equal(user, "lelebus")
+}
+
 func TestDoUseMultipartForm(t *testing.T) {
 	is := is.New(t)
 	var calls int
@@ -51,7 +83,7 @@ func TestDoUseMultipartForm(t *testing.T) {
 	defer srv.Close()
 
 	ctx := context.Background()
-	client := NewClient(srv.URL, UseMultipartForm())
+	client := NewClient(srv.URL, ForceMultipartForm())
 
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
@@ -78,7 +110,7 @@ func TestImmediatelyCloseReqBody(t *testing.T) {
 	defer srv.Close()
 
 	ctx := context.Background()
-	client := NewClient(srv.URL, ImmediatelyCloseReqBody(), UseMultipartForm())
+	client := NewClient(srv.URL, ImmediatelyCloseReqBody(), ForceMultipartForm())
 
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
@@ -106,7 +138,7 @@ func TestDoErr(t *testing.T) {
 	defer srv.Close()
 
 	ctx := context.Background()
-	client := NewClient(srv.URL, UseMultipartForm())
+	client := NewClient(srv.URL, ForceMultipartForm())
 
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
@@ -130,7 +162,7 @@ func TestDoServerErr(t *testing.T) {
 	defer srv.Close()
 
 	ctx := context.Background()
-	client := NewClient(srv.URL, UseMultipartForm())
+	client := NewClient(srv.URL, ForceMultipartForm())
 
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
@@ -157,7 +189,7 @@ func TestDoBadRequestErr(t *testing.T) {
 	defer srv.Close()
 
 	ctx := context.Background()
-	client := NewClient(srv.URL, UseMultipartForm())
+	client := NewClient(srv.URL, ForceMultipartForm())
 
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
@@ -183,7 +215,7 @@ func TestDoNoResponse(t *testing.T) {
 	defer srv.Close()
 
 	ctx := context.Background()
-	client := NewClient(srv.URL, UseMultipartForm())
+	client := NewClient(srv.URL, ForceMultipartForm())
 
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
@@ -207,7 +239,7 @@ func TestQuery(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
-	client := NewClient(srv.URL, UseMultipartForm())
+	client := NewClient(srv.URL, ForceMultipartForm())
 
 	req := NewRequest("query {}")
 
@@ -241,7 +273,7 @@ func TestQueryWithVars(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
-	client := NewClient(srv.URL, UseMultipartForm())
+	client := NewClient(srv.URL, ForceMultipartForm())
 
 	req := NewRequest("query {}").WithVars(map[string]interface{}{
 		"username": "lelebus",
@@ -262,6 +294,25 @@ func TestQueryWithVars(t *testing.T) {
 
 }
 
+func TestQueryWithNullVar(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.FormValue("variables"), `{"x":null}`+"\n")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, ForceMultipartForm())
+	_, err := client.Run(ctx, NewRequest("query {}").WithVar("x", nil), nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
 func TestFile(t *testing.T) {
 	is := is.New(t)
 
@@ -291,8 +342,454 @@ func TestFile(t *testing.T) {
 	is.NoErr(err)
 }
 
+func TestRequestOptions(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("X-Tenant"), "acme")
+		is.Equal(r.FormValue("variables"), `{"folder":"docs"}`+"\n")
+		file, header, err := r.FormFile("file")
+		is.NoErr(err)
+		defer file.Close()
+		is.Equal(header.Filename, "filename.txt")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest(`mutation A { a } mutation Upload { upload }`,
+		WithVariables(map[string]interface{}{"folder": "docs"}),
+		WithOperation("Upload"),
+		WithHeader("X-Tenant", "acme"),
+		WithFile("file", "filename.txt", strings.NewReader(`This is a file`)),
+	)
+	is.Equal(req.OperationName(), "Upload")
+	_, err := NewClient(srv.URL, UseMultipartForm()).Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestFileBytes(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		file, header, err := r.FormFile("file")
+		is.NoErr(err)
+		defer file.Close()
+		is.Equal(header.Filename, "filename.txt")
+		is.Equal(header.Header.Get("Content-Type"), "text/plain; charset=utf-8")
+		is.Equal(header.Header.Get("Content-Length"), "14")
+
+		b, err := io.ReadAll(file)
+		is.NoErr(err)
+		is.Equal(string(b), `This is a file`)
+
+		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL, UseMultipartForm())
+	req := NewRequest("query {}")
+	req.FileBytes("file", "filename.txt", []byte(`This is a file`))
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestUploadSpecStandard(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.FormValue("query"), "")
+		is.Equal(r.FormValue("operations"), `{"query":"mutation ($file: Upload!, $files: [Upload!]!) {}","variables":{"file":null,"files":[null],"folder":"docs"},"operationName":"Upload"}`+"\n")
+		is.Equal(r.FormValue("map"), `{"0":["variables.file"],"1":["variables.files.0"]}`+"\n")
+
+		file, header, err := r.FormFile("1")
+		is.NoErr(err)
+		defer file.Close()
+		is.Equal(header.Filename, "second.txt")
+		b, err := io.ReadAll(file)
+		is.NoErr(err)
+		is.Equal(string(b), `This is another file`)
+
+		_, err = io.WriteString(w, `{"data":{"value":"some data"}}`)
+		is.NoErr(err)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL, UseMultipartForm(), WithUploadSpec(UploadSpecStandard))
+	req := NewRequest("mutation ($file: Upload!, $files: [Upload!]!) {}").WithOperationName("Upload")
+	req.WithVars(map[string]interface{}{"folder": "docs", "files": []interface{}{nil}})
+	req.File("file", "first.txt", strings.NewReader(`This is a file`))
+	req.File("variables.files.0", "second.txt", strings.NewReader(`This is another file`))
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestRequestUploadSpec(t *testing.T) {
+	is := is.New(t)
+
+	var operations string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		operations = r.FormValue("operations")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseMultipartForm(), WithUploadSpec(UploadSpecStandard))
+	req := NewRequest("query {}").WithUploadSpec(UploadSpecLegacy)
+	req.File("file", "filename.txt", strings.NewReader(`This is a file`))
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(operations, "")
+
+	client = NewClient(srv.URL, UseMultipartForm())
+	req = NewRequest("query {}").WithUploadSpec(UploadSpecStandard)
+	req.File("file", "filename.txt", strings.NewReader(`This is a file`))
+	_, err = client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(operations, `{"query":"query {}","variables":{"file":null}}`+"\n")
+}
+
+func TestMultipartOperationName(t *testing.T) {
+	is := is.New(t)
+
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.NoErr(r.ParseMultipartForm(1 << 20))
+		form = r.MultipartForm.Value
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	newRequest := func() *Request {
+		req := NewRequest("mutation A { a } mutation Upload($file: Upload!) { upload(file: $file) }").WithOperationName("Upload")
+		req.File("file", "filename.txt", strings.NewReader(`This is a file`))
+		return req
+	}
+	client := NewClient(srv.URL, UseMultipartForm())
+	_, err := client.Run(ctx, newRequest(), nil)
+	is.NoErr(err)
+	is.Equal(form["operationName"], []string{"Upload"})
+
+	_, err = client.Run(ctx, newRequest().WithUploadSpec(UploadSpecStandard), nil)
+	is.NoErr(err)
+	var operations struct {
+		OperationName string
+	}
+	is.NoErr(json.Unmarshal([]byte(form["operations"][0]), &operations))
+	is.Equal(operations.OperationName, "Upload")
+	is.Equal(form["operationName"], nil)
+}
+
+func TestUploadLimits(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL, UseMultipartForm(), WithUploadLimits(2, 20))
+
+	req := NewRequest("query {}")
+	req.FileBytes("a", "a.txt", []byte(`0123456789`))
+	req.File("b", "b.txt", strings.NewReader(`0123456789`))
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+
+	req.FileBytes("c", "c.txt", []byte(`0`))
+	_, err = client.Run(ctx, req, nil)
+	is.Equal(err.Error(), "graphql: 3 files exceed the upload limit of 2 files")
+
+	req = NewRequest("query {}")
+	req.FileBytes("a", "a.txt", make([]byte, 21))
+	_, err = client.Run(ctx, req, nil)
+	is.Equal(err.Error(), "graphql: files exceed the upload limit of 20 bytes")
+
+	// sizes only known while reading
+	req = NewRequest("query {}").WithUploadSpec(UploadSpecStandard)
+	req.FileBytes("a", "a.txt", []byte(`0123456789`))
+	req.File("b", "b.txt", strings.NewReader(`0123456789!`))
+	_, err = client.Run(ctx, req, nil)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "graphql: files exceed the upload limit"))
+	is.Equal(calls, 1)
+}
+
+func TestFileContentLength(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(len(r.TransferEncoding), 0)
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(r.ContentLength, int64(len(b)))
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL, UseMultipartForm())
+	req := NewRequest("query {}")
+	// a reader of unknown size
+	req.File("file", "filename.txt", io.MultiReader(strings.NewReader(`This is `), strings.NewReader(`a file`)))
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestFileCancelledContext(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := NewClient(srv.URL, UseMultipartForm())
+	f := &cancellingReader{cancel: cancel}
+	req := NewRequest("query {}")
+	req.File("file", "filename.txt", f)
+	_, err := client.Run(ctx, req, nil)
+	is.True(errors.Is(err, context.Canceled))
+	is.Equal(f.reads, 1) // stopped after the first chunk
+	is.Equal(calls, 0)
+}
+
+// cancellingReader is an endless reader that cancels a context on
+// its first read.
+type cancellingReader struct {
+	cancel func()
+	reads  int
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	r.reads++
+	r.cancel()
+	return len(p), nil
+}
+
+func TestMultipartBoundary(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Content-Type"), "multipart/form-data; boundary=gqlclient-boundary")
+		is.Equal(r.FormValue("query"), "query {}")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, ForceMultipartForm(), WithMultipartBoundary("gqlclient-boundary"))
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestMultipartBoundaryInvalid(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, ForceMultipartForm(), WithMultipartBoundary("no;semicolons"))
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(err != nil)
+	is.True(strings.HasPrefix(err.Error(), `invalid multipart boundary "no;semicolons"`))
+	is.Equal(calls, 0)
+}
+
+func TestUseMultipartFormWithoutFiles(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Content-Type"), "application/json; charset=utf-8")
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":null}`+"\n")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseMultipartForm())
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestForceMultipartFormWithoutFiles(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.True(strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data; boundary="))
+		is.Equal(r.FormValue("query"), "query {}")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, ForceMultipartForm())
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestFormField(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.FormValue("query"), "query {}")
+		is.Equal(r.FormValue("csrfToken"), "token")
+		is.Equal(r.FormValue("folderId"), "42")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseMultipartForm())
+	req := NewRequest("query {}").WithFormField("csrfToken", "token").WithFormField("folderId", "42")
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestForceMultipartRequest(t *testing.T) {
+	is := is.New(t)
+
+	var contentTypes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentTypes = append(contentTypes, strings.Split(r.Header.Get("Content-Type"), ";")[0])
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	req := NewRequest("query {}").ForceMultipart()
+	req.File("file", "filename.txt", strings.NewReader(`This is a file`))
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	_, err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(contentTypes, []string{"multipart/form-data", "application/json"})
+
+	client = NewClient(srv.URL, ForceMultipartForm())
+	_, err = client.Run(ctx, NewRequest("query {}").ForceJSON(), nil)
+	is.NoErr(err)
+	is.Equal(contentTypes[2], "application/json")
+
+	req = NewRequest("query {}").ForceJSON()
+	req.File("file", "filename.txt", strings.NewReader(`This is a file`))
+	_, err = client.Run(ctx, req, nil)
+	is.Equal(err.Error(), "graphql: cannot send files with ForceJSON")
+	is.Equal(len(contentTypes), 3)
+}
+
 type roundTripperFunc func(req *http.Request) (*http.Response, error)
 
 func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return fn(req)
 }
+
+func TestUploadRequest(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.FormValue("operations"), `{"query":"mutation ($input: UploadInput!) {}","variables":{"input":{"file":null,"name":"docs","pages":[null,2]}}}`+"\n")
+		is.Equal(r.FormValue("map"), `{"0":["variables.input.file"],"1":["variables.input.pages.0"]}`+"\n")
+		file, header, err := r.FormFile("0")
+		is.NoErr(err)
+		defer file.Close()
+		is.Equal(header.Filename, "first.txt")
+		b, err := io.ReadAll(file)
+		is.NoErr(err)
+		is.Equal(string(b), `This is a file`)
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	input := struct {
+		Name  string        `json:"name"`
+		Pages []interface{} `json:"pages"`
+	}{Name: "docs", Pages: []interface{}{1, 2}}
+	req := NewUploadRequest("mutation ($input: UploadInput!) {}").
+		Var("input", input).
+		Attach("input.file", "first.txt", strings.NewReader(`This is a file`)).
+		Attach("input.pages.0", "second.txt", strings.NewReader(`This is another file`)).
+		Request()
+	is.NoErr(req.Validate())
+	_, err := NewClient(srv.URL).Run(ctx, req, nil) // no UseMultipartForm needed
+	is.NoErr(err)
+
+	// large integers are sent as they are
+	var operations string
+	idSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		operations = r.FormValue("operations")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer idSrv.Close()
+	req = NewUploadRequest("mutation ($input: UploadInput!) {}").
+		Var("input", map[string]interface{}{"id": int64(9007199254740993)}).
+		Attach("input.file", "first.txt", strings.NewReader(`This is a file`)).
+		Request()
+	_, err = NewClient(idSrv.URL).Run(ctx, req, nil)
+	is.NoErr(err)
+	is.Equal(operations, `{"query":"mutation ($input: UploadInput!) {}","variables":{"input":{"file":null,"id":9007199254740993}}}`+"\n")
+
+	for path, msg := range map[string]string{
+		"other":          `graphql: upload path "other" does not match a variable`,
+		"input.pages.5":  `graphql: upload path "input.pages.5" does not match a variable: no list position "5"`,
+		"input.name.raw": `graphql: upload path "input.name.raw" does not match a variable: "input.name" is not an object or a list`,
+	} {
+		req := NewUploadRequest("mutation ($input: UploadInput!) {}").
+			Var("input", input).
+			Attach(path, "first.txt", strings.NewReader(`This is a file`)).
+			Request()
+		is.Equal(req.Validate().Error(), msg)
+	}
+}
@@ -0,0 +1,154 @@
+package gqlclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRunNDJSON(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Header.Get("Content-Type"), "application/x-ndjson")
+		is.Equal(r.Header.Get("Accept"), "application/x-ndjson")
+		// read the whole body first: a plain HTTP/1.1 handler cannot
+		// read the request once it started writing the response
+		var lines []string
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		is.Equal(len(lines), 3)
+		for _, line := range lines {
+			var params struct {
+				Query     string
+				Variables map[string]interface{}
+			}
+			is.NoErr(json.Unmarshal([]byte(line), &params))
+			if params.Variables["id"] == "missing" {
+				fmt.Fprintln(w, `{"data": {"user": null}, "errors": [{"message": "not found"}]}`)
+			} else {
+				fmt.Fprintf(w, `{"data": {"user": {"id": %q}}}`+"\n", params.Variables["id"])
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var reqs []*Request
+	for _, id := range []string{"1", "missing", "3"} {
+		reqs = append(reqs, NewRequest(`query ($id: ID!) { user(id: $id) { id } }`).WithVar("id", id))
+	}
+	var results []string
+	err := NewClient(srv.URL).RunNDJSON(ctx, reqs, func(index int, data json.RawMessage, err error) {
+		if err != nil {
+			results = append(results, fmt.Sprintf("%d: %v", index, err))
+			return
+		}
+		results = append(results, fmt.Sprintf("%d: %s", index, data))
+	})
+	is.NoErr(err)
+	is.Equal(results, []string{
+		`0: {"user": {"id": "1"}}`,
+		`1: graphql: not found`,
+		`2: {"user": {"id": "3"}}`,
+	})
+}
+
+func TestRunNDJSONMissingResults(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		fmt.Fprintln(w, `{"data": {}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var calls int
+	err := NewClient(srv.URL).RunNDJSON(ctx, []*Request{NewRequest("{ a }"), NewRequest("{ b }")}, func(int, json.RawMessage, error) {
+		calls++
+	})
+	is.Equal(err.Error(), "graphql: got 1 NDJSON results for 2 requests")
+	is.Equal(calls, 1)
+}
+
+func TestRunNDJSONCancel(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		for i := 0; i < 3; i++ {
+			fmt.Fprintln(w, `{"data": {}}`)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var calls int
+	reqs := []*Request{NewRequest("{ a }"), NewRequest("{ b }"), NewRequest("{ c }")}
+	err := NewClient(srv.URL).RunNDJSON(ctx, reqs, func(int, json.RawMessage, error) {
+		calls++
+		cancel()
+	})
+	is.True(errors.Is(err, context.Canceled))
+	is.Equal(calls, 1)
+}
+
+func TestRunNDJSONClose(t *testing.T) {
+	is := is.New(t)
+
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client := NewClient(srv.URL)
+
+	errs := make(chan error)
+	go func() {
+		errs <- client.RunNDJSON(ctx, []*Request{NewRequest("{ a }")}, func(int, json.RawMessage, error) {})
+	}()
+	<-started
+	client.Close()
+	is.Equal(<-errs, ErrClientClosed)
+	is.True(ctx.Err() == nil) // cancelled before the deadline
+}
+
+func TestRunNDJSONDefaultTimeout(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	// no deadline, for the default timeout to apply
+	client := NewClient(srv.URL, WithDefaultTimeout(50*time.Millisecond))
+	err := client.RunNDJSON(context.Background(), []*Request{NewRequest("{ a }")}, func(int, json.RawMessage, error) {})
+	is.True(errors.Is(err, context.DeadlineExceeded))
+}
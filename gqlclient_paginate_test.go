@@ -0,0 +1,94 @@
+package gqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestPaginate(t *testing.T) {
+	is := is.New(t)
+
+	var cursors []interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Variables map[string]interface{}
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&params))
+		is.Equal(params.Variables["first"], 2.0)
+		cursors = append(cursors, params.Variables["after"])
+		page := len(cursors)
+		fmt.Fprintf(w, `{"data":{"users":{"nodes":["user%d","user%d"],"pageInfo":{"hasNextPage":%v,"endCursor":"c%d"}}}}`,
+			2*page-1, 2*page, page < 3, page)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	extract := func(data json.RawMessage) (PageInfo, interface{}, error) {
+		var resp struct {
+			Users struct {
+				Nodes    []string
+				PageInfo PageInfo
+			}
+		}
+		err := json.Unmarshal(data, &resp)
+		return resp.Users.PageInfo, resp.Users.Nodes, err
+	}
+	var users []string
+	accumulate := func(page interface{}) error {
+		users = append(users, page.([]string)...)
+		return nil
+	}
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query ($first: Int, $after: String) {}").WithVar("first", 2)
+	is.NoErr(client.Paginate(ctx, req, "after", extract, accumulate))
+	is.Equal(users, []string{"user1", "user2", "user3", "user4", "user5", "user6"})
+	is.Equal(cursors, []interface{}{nil, "c1", "c2"})
+	is.Equal(req.Vars(), map[string]interface{}{"first": 2}) // untouched
+
+	users, cursors = nil, nil
+	is.NoErr(client.Paginate(ctx, req, "after", extract, accumulate, MaxPages(2)))
+	is.Equal(users, []string{"user1", "user2", "user3", "user4"})
+
+	cursors = nil
+	cancelled, cancelPages := context.WithCancel(ctx)
+	err := client.Paginate(cancelled, req, "after", extract, func(page interface{}) error {
+		cancelPages()
+		return nil
+	})
+	is.Equal(err, context.Canceled)
+	is.Equal(len(cursors), 1)
+}
+
+func TestPaginateNoCursor(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"users":{"pageInfo":{"hasNextPage":true,"endCursor":null}}}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	err := NewClient(srv.URL).Paginate(ctx, NewRequest("query {}"), "after",
+		func(data json.RawMessage) (PageInfo, interface{}, error) {
+			var resp struct {
+				Users struct {
+					PageInfo PageInfo
+				}
+			}
+			err := json.Unmarshal(data, &resp)
+			return resp.Users.PageInfo, nil, err
+		},
+		func(page interface{}) error { return nil },
+	)
+	is.Equal(err.Error(), "graphql: page 1 has a next page but no end cursor")
+}
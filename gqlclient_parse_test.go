@@ -0,0 +1,153 @@
+package gqlclient
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestOperationName(t *testing.T) {
+	is := is.New(t)
+
+	for query, name := range map[string]string{
+		`query GetUser($id: ID!) { user(id: $id) { name } }`:                "GetUser",
+		`mutation UpdateUser { updateUser { id } }`:                         "UpdateUser",
+		`# query Commented { a }` + "\n" + `subscription OnEvent { event }`: "OnEvent",
+		`query @cached(opts: {ttl: 10}) { a }`:                              "anonymous",
+		`{ user { name } }`:                                                 "anonymous",
+		`fragment F on User { name } query WithFragment { user { ...F } }`:  "WithFragment",
+		`query A($in: In = {a: "{"}) { a } query B { b }`:                   "anonymous",
+		`query Real { a(s: "query Nope { b }", t: """ "{" """) }`:           "Real",
+	} {
+		is.Equal(NewRequest(query).OperationName(), name) // query
+	}
+
+	req := NewRequest(`query A { a } query B { b }`).WithOperationName("B")
+	is.Equal(req.OperationName(), "B")
+}
+
+func TestOperationType(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(NewRequest(`{ user { name } }`).OperationType(), "query")
+	is.Equal(NewRequest(`query GetUser { user { name } }`).OperationType(), "query")
+	is.Equal(NewRequest(`mutation { updateUser { id } }`).OperationType(), "mutation")
+	is.Equal(NewRequest(`subscription OnEvent { event }`).OperationType(), "subscription")
+
+	doc := `query A { a } mutation B { b }`
+	is.Equal(NewRequest(doc).OperationType(), "")
+	is.Equal(NewRequest(doc).WithOperationName("B").OperationType(), "mutation")
+	is.Equal(NewRequest(doc).WithOperationName("C").OperationType(), "")
+}
+
+func TestDirective(t *testing.T) {
+	is := is.New(t)
+
+	req := NewRequest(`query Stats($days: Int = 7) @route(to: "analytics", days: $days, retries: 3, tags: ["a"]) @cached {
+		stats(days: $days) @include(if: true) { visits }
+	}`)
+	args, ok := req.Directive("route")
+	is.True(ok)
+	is.Equal(args, map[string]string{"to": "analytics", "days": "$days", "retries": "3"})
+	args, ok = req.Directive("cached")
+	is.True(ok)
+	is.Equal(len(args), 0)
+	_, ok = req.Directive("include") // in the selection set
+	is.True(!ok)
+
+	doc := `query A @route(to: "a") { a } query B @route(to: """b""") { b }`
+	_, ok = NewRequest(doc).Directive("route")
+	is.True(!ok)
+	args, _ = NewRequest(doc).WithOperationName("B").Directive("route")
+	is.Equal(args["to"], "b")
+	_, ok = NewRequest(`{ a }`).Directive("route")
+	is.True(!ok)
+}
+
+func TestQueryHash(t *testing.T) {
+	is := is.New(t)
+
+	// the hash of the example in the Apollo persisted queries docs
+	is.Equal(NewRequest(`{__typename}`).QueryHash(), "ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38")
+	is.True(NewRequest(`{ __typename }`).QueryHash() != NewRequest(`{__typename}`).QueryHash())
+}
+
+func TestClientQueryHash(t *testing.T) {
+	is := is.New(t)
+
+	req := NewRequest(`{__typename}`)
+	is.Equal(NewClient("").QueryHash(req), req.QueryHash())
+
+	client := NewClient("", WithHasher(func(b []byte) string {
+		return "hash:" + string(b)
+	}))
+	is.Equal(client.QueryHash(req), "hash:{__typename}")
+}
+
+func TestResolveConditionals(t *testing.T) {
+	is := is.New(t)
+
+	for _, tt := range []struct {
+		query, want string
+		variables   map[string]interface{}
+	}{
+		{
+			query: `query Q($flag: Boolean!) { a b @include(if: $flag) { c } d @skip(if: $flag) }`,
+			want:  `query Q { a  d  }`,
+		},
+		{
+			query:     `query Q($flag: Boolean!) { a b @include(if: $flag) { c } }`,
+			variables: map[string]interface{}{"flag": false},
+			want:      `query Q($flag: Boolean!) { a b @include(if: $flag) { c } }`,
+		},
+		{
+			query: `query Q($id: ID!, $flag: Boolean = true) { user(id: $id) @include(if: $flag) { name } }`,
+			want:  `query Q($id: ID!, ) { user(id: $id)  { name } }`,
+		},
+		{
+			query: `query Q($id: ID!) { a user: user(id: $id) @include(if: false) { name } }`,
+			want:  `query Q { a  }`,
+		},
+		{
+			query: `{ user { ...F @skip(if: true) ... on User @include(if: false) { id } } } fragment F on User { id }`,
+			want:  `{ user {  __typename } } `,
+		},
+		{
+			query: `query Q($id: ID!, $n: Int) { a ...F @include(if: $unset) } fragment F on Query { user(id: $id) { ...G } } fragment G on User { name(n: $n) } fragment H on User { id }`,
+			want:  `query Q { a  }   fragment H on User { id }`,
+		},
+		{
+			query: `{ a ...F @skip(if: true) b { ...F } } fragment F on Query { c }`,
+			want:  `{ a  b { ...F } } fragment F on Query { c }`,
+		},
+		{
+			query: `query Q($flag: Boolean) { a @include(if: $flag) @cached b @skip(if: $other) }`,
+			want:  `query Q {  b  }`,
+		},
+		{
+			query: `{ a(s: "@include(if: false)") }`,
+			want:  `{ a(s: "@include(if: false)") }`,
+		},
+	} {
+		is.Equal(resolveConditionals(tt.query, tt.variables), tt.want) // query
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	is := is.New(t)
+
+	key := NewRequest(`query GetUser($id: ID!) { user(id: $id) { name } }`).
+		WithVars(map[string]interface{}{"id": "42", "opts": map[string]interface{}{"a": 1, "b": 2}}).
+		Key()
+	is.Equal(len(key), 64)
+	same := NewRequest("# get a user\nquery GetUser($id: ID!) {\n\tuser(id: $id) {\n\t\tname,\n\t}\n}").
+		WithVars(map[string]interface{}{"opts": map[string]interface{}{"b": 2, "a": 1}, "id": "42"}).
+		Key()
+	is.Equal(same, key)
+
+	is.True(NewRequest(`query GetUser($id: ID!) { user(id: $id) { name } }`).WithVar("id", "43").Key() != key)
+	is.True(NewRequest(`query GetUser($id: ID!) { user(id: $id) { name } }`).Key() != key)
+	is.True(NewRequest(`query GetUser($id: ID!) { user(id: $id) { name(s: "a  b") } }`).Key() !=
+		NewRequest(`query GetUser($id: ID!) { user(id: $id) { name(s: "a b") } }`).Key()) // strings are kept as is
+	is.Equal(len(NewRequest(`{ a }`).WithVar("f", func() {}).Key()), 64) // variables cannot be encoded
+}
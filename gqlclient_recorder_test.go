@@ -0,0 +1,122 @@
+package gqlclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRecorder(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+		if r.Header.Get("X-Fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `oops`)
+			return
+		}
+		io.WriteString(w, `{"data":{"user":{"name":"lelebus"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var recs []Interaction
+	client := NewClient(srv.URL, StreamDecode(), WithRecorder(func(rec Interaction) {
+		recs = append(recs, rec)
+	}))
+	req := NewRequest(`query GetUser($id: ID!) { user(id: $id) { name } }`).WithVar("id", "1")
+	req.Header.Set("X-Tenant", "acme")
+	var responseData map[string]interface{}
+	_, err := client.Run(ctx, req, &responseData)
+	is.NoErr(err)
+
+	req = NewRequest(`query GetUser($id: ID!) { user(id: $id) { name } }`).WithVar("id", "2")
+	req.Header.Set("X-Fail", "1")
+	_, err = client.Run(ctx, req, &responseData)
+	is.True(err != nil)
+
+	is.Equal(len(recs), 2)
+	is.Equal(recs[0].OperationName, "GetUser")
+	is.Equal(recs[0].Variables, map[string]interface{}{"id": "1"})
+	is.Equal(recs[0].RequestHeader.Get("X-Tenant"), "acme")
+	is.Equal(recs[0].RequestBody, `{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":"1"}}`+"\n")
+	is.Equal(recs[0].StatusCode, http.StatusOK)
+	is.Equal(recs[0].ResponseHeader.Get("X-Request-Id"), "abc")
+	is.Equal(recs[0].ResponseBody, `{"data":{"user":{"name":"lelebus"}}}`)
+	is.True(recs[0].Duration > 0)
+	is.NoErr(recs[0].Err)
+
+	is.Equal(recs[1].StatusCode, http.StatusInternalServerError)
+	is.Equal(recs[1].ResponseBody, `oops`)
+	is.Equal(recs[1].Err, err)
+}
+
+func TestCassette(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"user":{"name":"lelebus"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	newRequest := func(id int, traceID string) *Request {
+		req := NewRequest(`query GetUser($id: ID!) { user(id: $id) { name } }`).WithVar("id", id)
+		req.Header.Set("X-Tenant", "acme")
+		req.Header.Set("X-Trace-Id", traceID)
+		return req
+	}
+
+	// record
+	var recs []Interaction
+	client := NewClient(srv.URL, WithRecorder(func(rec Interaction) {
+		recs = append(recs, rec)
+	}))
+	_, err := client.Run(ctx, newRequest(1, "trace-1"), nil)
+	is.NoErr(err)
+	b, err := json.Marshal(recs)
+	is.NoErr(err)
+
+	// replay
+	cassette, err := LoadCassette(bytes.NewReader(b))
+	is.NoErr(err)
+	cassette.Strict = true
+	cassette.IgnoreHeaders = []string{"x-trace-id"}
+	client = NewClient(srv.URL, WithCassette(cassette))
+	var responseData map[string]interface{}
+	_, err = client.Run(ctx, newRequest(1, "trace-2"), &responseData)
+	is.NoErr(err)
+	is.Equal(responseData["user"], map[string]interface{}{"name": "lelebus"})
+	is.Equal(calls, 1) // replayed
+
+	_, err = client.Run(ctx, newRequest(2, "trace-2"), nil)
+	is.Equal(err.Error(), "graphql: no recorded interaction for operation GetUser")
+	req := newRequest(1, "trace-2")
+	req.Header.Set("X-Tenant", "other")
+	_, err = client.Run(ctx, req, nil)
+	is.True(err != nil)
+	is.Equal(calls, 1)
+
+	cassette.Strict = false
+	_, err = client.Run(ctx, newRequest(2, "trace-2"), nil)
+	is.NoErr(err)
+	is.Equal(calls, 2) // passed through
+
+	_, err = LoadCassette(strings.NewReader(`{`))
+	is.True(err != nil)
+}
@@ -0,0 +1,112 @@
+package gqlclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestGraphQLRoundTripper(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.True(len(b) > 0) // body left for the transport
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var operations []string
+	transport := &GraphQLRoundTripper{
+		Wrap: func(operationName string, r *http.Request, next http.RoundTripper) (*http.Response, error) {
+			operations = append(operations, operationName)
+			return next.RoundTrip(r)
+		},
+	}
+	client := NewClient(srv.URL, WithHTTPClient(&http.Client{Transport: transport}), UseMultipartForm())
+	_, err := client.Run(ctx, NewRequest(`query GetUser { user { name } }`), nil)
+	is.NoErr(err)
+	req := NewRequest(`mutation Upload($file: Upload!) { upload(file: $file) }`)
+	req.File("file", "filename.txt", strings.NewReader(`This is a file`))
+	_, err = client.Run(ctx, req, nil)
+	is.NoErr(err)
+
+	// requests not sent by a Client
+	httpClient := &http.Client{Transport: transport}
+	body := `{"query": "query A { a } query B { b }", "operationName": "B"}`
+	res, err := httpClient.Post(srv.URL, "application/json", strings.NewReader(body))
+	is.NoErr(err)
+	res.Body.Close()
+	r, err := http.NewRequest(http.MethodPost, srv.URL, io.NopCloser(strings.NewReader(`{"query": "{ a }"}`)))
+	is.NoErr(err)
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	res, err = httpClient.Do(r)
+	is.NoErr(err)
+	res.Body.Close()
+
+	is.Equal(operations, []string{"GetUser", "Upload", "B", "anonymous"})
+}
+
+func TestGraphQLRoundTripperLeavesRequest(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query": "query A { a }"}`)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	var operation string
+	transport := &GraphQLRoundTripper{
+		Wrap: func(operationName string, r *http.Request, next http.RoundTripper) (*http.Response, error) {
+			operation = operationName
+			return next.RoundTrip(r)
+		},
+	}
+	body := io.NopCloser(strings.NewReader(`{"query": "query A { a }"}`))
+	r, err := http.NewRequest(http.MethodPost, srv.URL, body)
+	is.NoErr(err)
+	r.Header.Set("Content-Type", "application/json")
+	res, err := transport.RoundTrip(r)
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(operation, "A")
+	is.True(r.Body == body) // the body is buffered on a clone
+}
+
+func TestRequestFromContext(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("query {}")
+	var seen *Request
+	client := NewClient(srv.URL, WithHTTPClient(&http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			seen = RequestFromContext(r.Context())
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}))
+	_, err := client.Run(ctx, req, nil)
+	is.NoErr(err)
+	is.True(seen == req)
+	is.True(RequestFromContext(ctx) == nil)
+}
@@ -0,0 +1,341 @@
+package gqlclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRunWithStats(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	var resp struct {
+		Value string
+	}
+	stats, err := client.RunWithStats(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(stats.Response.StatusCode, http.StatusOK)
+	is.Equal(stats.Trace, nil) // no trace without WithHTTPTrace
+	is.Equal(resp.Value, "some data")
+}
+
+func TestHTTPTrace(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithHTTPClient(&http.Client{Transport: &http.Transport{}}), WithHTTPTrace())
+
+	stats, err := client.RunWithStats(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.True(stats.Trace != nil)
+	is.True(!stats.Trace.ConnReused)
+	is.True(stats.Trace.Connect > 0)
+
+	stats, err = client.RunWithStats(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.True(stats.Trace.ConnReused) // keep-alive
+}
+
+func TestTolerantErrors(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"data": {"value": "some data", "broken": null},
+			"errors": [{"message": "broken resolver"}, {"message": "another one"}]
+		}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var resp struct {
+		Value string
+	}
+	stats, err := NewClient(srv.URL).RunWithStats(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err.Error(), "graphql: broken resolver\ngraphql: another one")
+	is.Equal(len(stats.Errors), 2)
+
+	client := NewClient(srv.URL, TolerantErrors())
+	stats, err = client.RunWithStats(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "some data")
+	is.Equal(len(stats.Errors), 2)
+	is.Equal(stats.Errors[1].Message, "another one")
+}
+
+func TestStatsBytes(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	stats, err := NewClient(srv.URL).RunWithStats(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(stats.RequestBytes, int64(len(`{"query":"query {}","variables":null}`+"\n")))
+	is.Equal(stats.ResponseBytes, int64(len(`{"data":{"value":"some data"}}`)))
+
+	req := NewRequest("query {}")
+	req.FileBytes("file", "filename.txt", []byte(strings.Repeat("x", 1000)))
+	stats, err = NewClient(srv.URL, UseMultipartForm()).RunWithStats(ctx, req, nil)
+	is.NoErr(err)
+	is.True(stats.RequestBytes > 1000) // the whole multipart body
+}
+
+func TestStatsBytesGzip(t *testing.T) {
+	is := is.New(t)
+
+	response := `{"data":{"value":"` + strings.Repeat("x", 1000) + `"}}`
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	io.WriteString(zw, response)
+	is.NoErr(zw.Close())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Header.Get("Accept-Encoding"), "gzip")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	for _, streaming := range []bool{false, true} {
+		var opts []ClientOption
+		if streaming {
+			opts = append(opts, StreamDecode())
+		}
+		var resp struct {
+			Value string
+		}
+		stats, err := NewClient(srv.URL, opts...).RunWithStats(ctx, NewRequest("query {}"), &resp)
+		is.NoErr(err)
+		is.Equal(len(resp.Value), 1000)
+		is.Equal(stats.ResponseBytes, int64(compressed.Len())) // before decompressing
+		is.Equal(stats.Response.Header.Get("Content-Encoding"), "")
+	}
+}
+
+func TestStatsTLS(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var handled []*tls.ConnectionState
+	client := NewClient(srv.URL, WithHTTPClient(srv.Client()), WithTLSHandler(func(state *tls.ConnectionState) {
+		handled = append(handled, state)
+	}))
+	stats, err := client.RunWithStats(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.True(stats.TLS() != nil)
+	is.True(stats.TLS().Version >= tls.VersionTLS12)
+	is.Equal(len(handled), 1)
+	is.Equal(handled[0].CipherSuite, stats.TLS().CipherSuite)
+}
+
+func TestWarnings(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"data": {"value": "some data"},
+			"extensions": {
+				"warnings": [{"message": "field value is deprecated", "code": "DEPRECATED"}],
+				"notices": [{"message": "slow query"}],
+				"broken": 42
+			}
+		}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var handled []Warning
+	client := NewClient(srv.URL, WithWarningHandler(func(warnings []Warning) {
+		handled = append(handled, warnings...)
+	}))
+	var resp struct {
+		Value string
+	}
+	stats, err := client.RunWithStats(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "some data")
+	is.Equal(stats.Warnings, []Warning{{Message: "field value is deprecated", Code: "DEPRECATED"}})
+	is.Equal(handled, stats.Warnings)
+
+	stats, err = NewClient(srv.URL, WithWarningsKey("notices"), StreamDecode()).RunWithStats(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(stats.Warnings, []Warning{{Message: "slow query"}})
+
+	stats, err = NewClient(srv.URL, WithWarningsKey("broken")).RunWithStats(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err) // malformed warnings are ignored
+	is.Equal(len(stats.Warnings), 0)
+
+	handled = nil
+	client = NewClient(srv.URL, MinimalResponses(), WithWarningHandler(func(warnings []Warning) {
+		handled = append(handled, warnings...)
+	}))
+	stats, err = client.RunWithStats(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(len(stats.Warnings), 0) // extensions are ignored
+	is.Equal(len(handled), 0)
+}
+
+func TestStreamRequestBody(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.TransferEncoding, []string{"chunked"})
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"mutation {}","variables":{"id":"42"}}`+"\n")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var resp struct {
+		Value string
+	}
+	stats, err := NewClient(srv.URL, StreamRequestBody()).RunWithStats(ctx, NewRequest("mutation {}").WithVar("id", "42"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "some data")
+	is.Equal(stats.RequestBytes, int64(-1))
+
+	_, err = NewClient(srv.URL, StreamRequestBody(), DryRun()).Run(ctx, NewRequest("mutation {}"), nil)
+	is.NoErr(err) // never sent
+
+	client := NewClient(srv.URL, StreamRequestBody(), WithRecorder(func(rec Interaction) {}))
+	_, err = client.Run(ctx, NewRequest("mutation {}"), nil)
+	is.Equal(err.Error(), "graphql: StreamRequestBody cannot be used with WithRecorder, which records the request body")
+}
+
+func TestErrorSeverity(t *testing.T) {
+	is := is.New(t)
+
+	fieldErrors := `{"message": "avatar unavailable", "path": ["user", "avatar"], "extensions": {"code": "UNAVAILABLE"}}`
+	body := `{"data": {"user": {"name": "lelebus", "avatar": null}}, "errors": [` + fieldErrors + `]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var handled []Warning
+	client := NewClient(srv.URL, WithWarningHandler(func(warnings []Warning) {
+		handled = append(handled, warnings...)
+	}), WithErrorSeverity(func(err GraphQLError) Severity {
+		if len(err.Path) > 0 {
+			return SeverityWarning
+		}
+		return SeverityFatal
+	}))
+	var resp struct {
+		User struct {
+			Name string
+		}
+	}
+	stats, err := client.RunWithStats(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.User.Name, "lelebus")
+	is.Equal(len(stats.Errors), 1)
+	is.Equal(len(stats.Warnings), 1)
+	is.Equal(stats.Warnings[0].Message, "avatar unavailable")
+	is.Equal(stats.Warnings[0].Code, "UNAVAILABLE")
+	is.Equal(stats.Warnings[0].Error.Path, []interface{}{"user", "avatar"})
+	is.Equal(len(handled), 1)
+
+	body = `{"data": {"user": null}, "errors": [` + fieldErrors + `, {"message": "rate limited"}]}`
+	_, err = client.Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err.Error(), "graphql: rate limited") // only the fatal error
+
+	_, err = NewClient(srv.URL).Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err.Error(), "graphql: avatar unavailable\ngraphql: rate limited") // all fatal by default
+}
+
+func TestGzipEmptyErrorResponse(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	_, err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: server returned a non-200 status code: 502")
+}
+
+// headerRecorder records the Accept-Encoding header of the requests it
+// passes on.
+type headerRecorder struct {
+	next           http.RoundTripper
+	acceptEncoding []string
+}
+
+func (h *headerRecorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	h.acceptEncoding = append(h.acceptEncoding, r.Header.Get("Accept-Encoding"))
+	return h.next.RoundTrip(r)
+}
+
+func TestGzipTransportOwnership(t *testing.T) {
+	is := is.New(t)
+
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Accept-Encoding"))
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithTransport(func(transport *http.Transport) {
+		transport.DisableCompression = true
+	}))
+	_, err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(received, []string{""}) // compression stays disabled
+
+	// middleware of a custom http.Client sees the request as before
+	middleware := &headerRecorder{next: http.DefaultTransport}
+	_, err = NewClient(srv.URL, WithHTTPClient(&http.Client{Transport: middleware})).Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(middleware.acceptEncoding, []string{""})
+}
@@ -0,0 +1,32 @@
+package gqlclient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestNull(t *testing.T) {
+	is := is.New(t)
+
+	var user struct {
+		Name     Null[string]
+		Nickname Null[string]
+		Age      Null[int]
+	}
+	err := json.Unmarshal([]byte(`{"name": "lelebus", "nickname": null}`), &user)
+	is.NoErr(err)
+	is.Equal(user.Name, NewNull("lelebus"))
+	is.Equal(user.Nickname, Null[string]{Present: true})
+	is.Equal(user.Age, Null[int]{})
+	is.Equal(*user.Name.Ptr(), "lelebus")
+	is.Equal(user.Nickname.Ptr(), nil)
+
+	err = json.Unmarshal([]byte(`{"age": "old"}`), &user)
+	is.True(err != nil)
+
+	b, err := json.Marshal(Vars{}.Set("age", NewNull(42)).Set("nickname", Null[string]{}))
+	is.NoErr(err)
+	is.Equal(string(b), `{"age":42,"nickname":null}`)
+}
@@ -0,0 +1,132 @@
+package gqlclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestVars(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":{"limit":10,"name":"lelebus","order":"DESC"}}`+"\n")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	vars := Vars{}.Set("name", "lelebus").SetInt("limit", 10).SetEnum("order", "DESC")
+	_, err := client.Run(ctx, NewRequest("query {}").WithVars(vars), nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestVarsInvalidEnum(t *testing.T) {
+	is := is.New(t)
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+
+	for _, name := range []string{"in progress", "1ST", "true", ""} {
+		vars := Vars{}.SetEnum("status", name)
+		_, err := client.Run(ctx, NewRequest("query {}").WithVars(vars), nil)
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), "invalid enum value")) // name
+	}
+	is.Equal(calls, 0)
+}
+
+func TestValidateVars(t *testing.T) {
+	is := is.New(t)
+
+	req := NewRequest("query {}").WithVars(Vars{}.Set("name", "lelebus").SetInt("limit", 10))
+	is.NoErr(req.ValidateVars())
+
+	req.WithVar("callback", func() {})
+	err := req.ValidateVars()
+	is.True(err != nil)
+	is.Equal(err.Error(), `graphql: variable "callback" cannot be encoded: json: unsupported type: func()`)
+}
+
+func TestValidateRequest(t *testing.T) {
+	is := is.New(t)
+
+	req := NewRequest("query {}").WithVars(Vars{}.Set("name", "lelebus"))
+	req.File("file", "filename.txt", strings.NewReader(`This is a file`))
+	is.NoErr(req.Validate())
+
+	is.Equal(NewRequest(" \n").Validate().Error(), "graphql: empty query")
+	is.Equal(NewRequest("query A { a } query B { b }").Validate(), ErrAmbiguousOperation)
+
+	req = NewRequest("query {}").WithVar("callback", func() {})
+	is.True(req.Validate() != nil)
+
+	req = NewRequest("query {}")
+	req.File("file", "filename.txt", nil)
+	is.Equal(req.Validate().Error(), `graphql: file "filename.txt" has no reader`)
+
+	f, err := os.CreateTemp(t.TempDir(), "upload")
+	is.NoErr(err)
+	is.NoErr(f.Close())
+	req = NewRequest("query {}")
+	req.File("file", "upload.txt", f)
+	is.True(req.Validate() != nil)
+
+	req = NewRequest("").WithRawBody([]byte(`{"query":"{}"}`), "application/json")
+	is.NoErr(req.Validate())
+}
+
+func TestMergeVars(t *testing.T) {
+	is := is.New(t)
+
+	defaults := map[string]interface{}{
+		"limit": 10,
+		"filter": map[string]interface{}{
+			"status": "ACTIVE",
+			"owner":  map[string]interface{}{"team": "core"},
+		},
+		"tags": []interface{}{"a"},
+	}
+	input := map[string]interface{}{
+		"filter": Vars{}.Set("owner", map[string]interface{}{"id": "42"}),
+		"tags":   []interface{}{"b"},
+	}
+	merged := MergeVars(defaults, input, nil, map[string]interface{}{"limit": 20})
+	is.Equal(merged, map[string]interface{}{
+		"limit": 20,
+		"filter": map[string]interface{}{
+			"status": "ACTIVE",
+			"owner":  map[string]interface{}{"team": "core", "id": "42"},
+		},
+		"tags": []interface{}{"b"},
+	})
+
+	// the inputs are left untouched
+	is.Equal(defaults["limit"], 10)
+	is.Equal(defaults["filter"].(map[string]interface{})["owner"], map[string]interface{}{"team": "core"})
+	merged["filter"].(map[string]interface{})["status"] = "DELETED"
+	is.Equal(defaults["filter"].(map[string]interface{})["status"], "ACTIVE")
+	is.Equal(len(MergeVars()), 0)
+}
@@ -0,0 +1,61 @@
+package gqlclient
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Null holds a nullable GraphQL value, for use in response structs where
+// JSON null and an absent field must be told apart:
+//
+//	var resp struct {
+//	    User struct {
+//	        Nickname gqlclient.Null[string]
+//	    }
+//	}
+//
+// After decoding, Valid reports whether the field held a value, and
+// Present whether the field was in the response at all, null or not.
+// Encoding a Null gives its Value, or null if it is not Valid, so Null can
+// be used in variables too.
+type Null[T any] struct {
+	Value   T
+	Valid   bool
+	Present bool
+}
+
+// NewNull makes a valid Null holding value.
+func NewNull[T any](value T) Null[T] {
+	return Null[T]{Value: value, Valid: true, Present: true}
+}
+
+// Ptr gets a pointer to the value of n, or nil if n is not Valid.
+func (n Null[T]) Ptr() *T {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Value
+}
+
+// UnmarshalJSON decodes a JSON value, or null, into n.
+func (n *Null[T]) UnmarshalJSON(b []byte) error {
+	n.Present = true
+	if bytes.Equal(b, []byte("null")) {
+		var zero T
+		n.Value, n.Valid = zero, false
+		return nil
+	}
+	if err := json.Unmarshal(b, &n.Value); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// MarshalJSON encodes the value of n, or null if n is not Valid.
+func (n Null[T]) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Value)
+}
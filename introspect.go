@@ -0,0 +1,206 @@
+package gqlclient
+
+import "context"
+
+// IntrospectionQuery is the standard introspection query, as sent by
+// Introspect. It asks for the whole schema, descending seven levels into
+// wrapped types, which covers types such as [[Int!]!]!.
+const IntrospectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types {
+      ...FullType
+    }
+    directives {
+      name
+      description
+      locations
+      args {
+        ...InputValue
+      }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: true) {
+    name
+    description
+    args {
+      ...InputValue
+    }
+    type {
+      ...TypeRef
+    }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields {
+    ...InputValue
+  }
+  interfaces {
+    ...TypeRef
+  }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes {
+    ...TypeRef
+  }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type {
+    ...TypeRef
+  }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+              ofType {
+                kind
+                name
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// Introspect runs IntrospectionQuery against the server and returns the
+// schema it describes.
+//
+//	schema, err := client.Introspect(ctx)
+//	user := schema.Type("User")
+func (c *Client) Introspect(ctx context.Context) (*IntrospectionResult, error) {
+	var resp struct {
+		Schema IntrospectionResult `json:"__schema"`
+	}
+	if _, err := c.Run(ctx, NewRequest(IntrospectionQuery), &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Schema, nil
+}
+
+// IntrospectionResult is the schema of a server, as returned by
+// Introspect.
+type IntrospectionResult struct {
+	QueryType        *TypeRef                 `json:"queryType"`
+	MutationType     *TypeRef                 `json:"mutationType"`
+	SubscriptionType *TypeRef                 `json:"subscriptionType"`
+	Types            []IntrospectionType      `json:"types"`
+	Directives       []IntrospectionDirective `json:"directives"`
+}
+
+// Type gets the type with the given name, or nil if there is none.
+func (r *IntrospectionResult) Type(name string) *IntrospectionType {
+	for i := range r.Types {
+		if r.Types[i].Name == name {
+			return &r.Types[i]
+		}
+	}
+	return nil
+}
+
+// IntrospectionType is a named type of a schema. Depending on its Kind,
+// such as OBJECT, INPUT_OBJECT or ENUM, some of its fields are empty.
+type IntrospectionType struct {
+	Kind          string                    `json:"kind"`
+	Name          string                    `json:"name"`
+	Description   string                    `json:"description"`
+	Fields        []IntrospectionField      `json:"fields"`
+	InputFields   []IntrospectionInputValue `json:"inputFields"`
+	Interfaces    []TypeRef                 `json:"interfaces"`
+	EnumValues    []IntrospectionEnumValue  `json:"enumValues"`
+	PossibleTypes []TypeRef                 `json:"possibleTypes"`
+}
+
+// IntrospectionField is a field of an object or interface type.
+type IntrospectionField struct {
+	Name              string                    `json:"name"`
+	Description       string                    `json:"description"`
+	Args              []IntrospectionInputValue `json:"args"`
+	Type              TypeRef                   `json:"type"`
+	IsDeprecated      bool                      `json:"isDeprecated"`
+	DeprecationReason string                    `json:"deprecationReason"`
+}
+
+// IntrospectionInputValue is an argument, or a field of an input type.
+// DefaultValue is the default value written as a GraphQL literal, or nil
+// if there is none.
+type IntrospectionInputValue struct {
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	Type         TypeRef `json:"type"`
+	DefaultValue *string `json:"defaultValue"`
+}
+
+// IntrospectionEnumValue is a value of an enum type.
+type IntrospectionEnumValue struct {
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	IsDeprecated      bool   `json:"isDeprecated"`
+	DeprecationReason string `json:"deprecationReason"`
+}
+
+// IntrospectionDirective is a directive supported by a schema.
+type IntrospectionDirective struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Locations   []string                  `json:"locations"`
+	Args        []IntrospectionInputValue `json:"args"`
+}
+
+// TypeRef refers to a type, which is either named or wraps another type
+// in a list or a non-null type, with Kind LIST or NON_NULL.
+type TypeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *TypeRef `json:"ofType"`
+}
+
+// String writes the type as in a GraphQL document, such as [User!]!.
+func (t TypeRef) String() string {
+	switch {
+	case t.OfType == nil:
+		return t.Name
+	case t.Kind == "LIST":
+		return "[" + t.OfType.String() + "]"
+	case t.Kind == "NON_NULL":
+		return t.OfType.String() + "!"
+	}
+	return t.Name
+}
@@ -0,0 +1,157 @@
+package gqlclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// RunNDJSON sends reqs to a bulk endpoint accepting newline-delimited JSON
+// (NDJSON), with one operation per line, and reads back one result per
+// line, in the same order. onResult is called for each result as it
+// arrives, with the index of its request, its data field and the first
+// GraphQL error of the result, if any.
+//
+// Requests are written and results read as a stream, so a bulk job never
+// needs to be held in memory whole. The context is checked between lines:
+// once it is done, RunNDJSON stops and returns its error.
+// Requests cannot have files or a raw body, and only the headers of the
+// Client, not those of each Request, are sent.
+//
+// Like Run, RunNDJSON returns ErrClientClosed if the Client is closed
+// while it is in flight, and follows WithMaxConcurrency, for which the
+// whole bulk job counts as one request, WithDefaultTimeout,
+// WithBaseContext, ImmediatelyCloseReqBody, WithEndpointDecorator, called
+// with the first request, and DryRun, which sends nothing and reports no
+// results. Since a bulk job is not a single operation, stubs,
+// WithCassette, WithRecorder, WithAuditHook, WithHTTPTrace and the options
+// inspecting whole responses, such as WithResponseValidator, do not apply.
+func (c *Client) RunNDJSON(ctx context.Context, reqs []*Request, onResult func(index int, data json.RawMessage, err error)) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	ctx, cancel := c.watchClose(ctx)
+	defer cancel()
+	err := c.runNDJSON(ctx, reqs, onResult)
+	if err != nil && ctx.Err() != nil && c.isClosed() {
+		return ErrClientClosed
+	}
+	return err
+}
+
+// runNDJSON runs a bulk job, once the Client is known to be open.
+func (c *Client) runNDJSON(ctx context.Context, reqs []*Request, onResult func(index int, data json.RawMessage, err error)) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	for i, req := range reqs {
+		if req.err != nil {
+			return errors.Wrapf(req.err, "request %d", i)
+		}
+		if len(req.files) > 0 || req.rawBody != nil {
+			return fmt.Errorf("graphql: request %d: cannot send files or a raw body as NDJSON", i)
+		}
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	ctx, cancelWrites := context.WithCancel(ctx)
+	defer cancelWrites()
+	if c.dryRun {
+		c.logf(">> ndjson: %d requests, not sent in dry-run mode", len(reqs))
+		return nil
+	}
+
+	// Write the requests as they are sent
+	pr, pw := io.Pipe()
+	go func() {
+		enc := json.NewEncoder(pw)
+		for _, req := range reqs {
+			if err := ctx.Err(); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			line := struct {
+				Query         string                 `json:"query"`
+				Variables     map[string]interface{} `json:"variables"`
+				OperationName string                 `json:"operationName,omitempty"`
+			}{
+				Query:         req.query,
+				Variables:     req.variables,
+				OperationName: req.operationName,
+			}
+			if err := enc.Encode(line); err != nil {
+				pw.CloseWithError(errors.Wrap(err, "encode request"))
+				return
+			}
+		}
+		pw.Close()
+	}()
+	c.logf(">> ndjson: %d requests", len(reqs))
+
+	defer pr.Close()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpointFor(reqs[0]), pr)
+	if err != nil {
+		return err
+	}
+	r.Close = c.closeReq
+	if err := c.decorate(reqs[0], r); err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	c.setHeaders(ctx, http.Header{"Accept": {"application/x-ndjson"}}, r)
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	res, err := c.httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if c.isErrorStatus(res.StatusCode) {
+		return fmt.Errorf("graphql: server returned a non-200 status code: %v", res.StatusCode)
+	}
+
+	// Read the results as they arrive
+	lines := bufio.NewReader(res.Body)
+	index := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, err := lines.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if index >= len(reqs) {
+				return errors.New("graphql: more NDJSON results than requests")
+			}
+			c.logf("<< %s", line)
+			var gr graphResponse
+			if err := c.decodeResponse(line, &gr); err != nil {
+				return errors.Wrapf(err, "decoding result %d", index)
+			}
+			var resultErr error
+			if len(gr.Errors) > 0 {
+				resultErr = c.graphErrors(res, gr.Errors, gr.Data != nil, &Stats{})
+			}
+			onResult(index, gr.Data, resultErr)
+			index++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading body")
+		}
+	}
+	if index < len(reqs) {
+		return fmt.Errorf("graphql: got %d NDJSON results for %d requests", index, len(reqs))
+	}
+	return nil
+}
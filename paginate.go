@@ -0,0 +1,99 @@
+package gqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PageInfo is the pagination state of a Relay-style connection, as read
+// from its pageInfo field.
+type PageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// PageOption are functions that are passed into Paginate to modify its
+// behaviour.
+type PageOption func(*paginator)
+
+// MaxPages makes Paginate stop after n pages, whether or not more are
+// available.
+func MaxPages(n int) PageOption {
+	return func(p *paginator) {
+		p.maxPages = n
+	}
+}
+
+type paginator struct {
+	maxPages int
+}
+
+// Paginate runs a query over a cursor-based connection page after page,
+// until the last one. The first page is fetched with req as is, and every
+// following page with the end cursor of the previous one set as the
+// variable cursorVar; req itself is not modified.
+//
+// extract is called with the data field of each response, and returns the
+// page info of the connection along with the items of the page, which are
+// then passed to accumulate:
+//
+//	var users []User
+//	err := client.Paginate(ctx, req, "after",
+//	    func(data json.RawMessage) (gqlclient.PageInfo, interface{}, error) {
+//	        var resp struct {
+//	            Users struct {
+//	                Nodes    []User
+//	                PageInfo gqlclient.PageInfo
+//	            }
+//	        }
+//	        err := json.Unmarshal(data, &resp)
+//	        return resp.Users.PageInfo, resp.Users.Nodes, err
+//	    },
+//	    func(page interface{}) error {
+//	        users = append(users, page.([]User)...)
+//	        return nil
+//	    },
+//	)
+//
+// Paginate stops at the first error, from Run, extract or accumulate, and
+// when ctx is done. A page claiming a next page without giving an end
+// cursor is an error, as it would be fetched again and again.
+func (c *Client) Paginate(ctx context.Context, req *Request, cursorVar string, extract func(data json.RawMessage) (PageInfo, interface{}, error), accumulate func(page interface{}) error, opts ...PageOption) error {
+	var p paginator
+	for _, optionFunc := range opts {
+		optionFunc(&p)
+	}
+	pageReq := req
+	for pages := 1; ; pages++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var data json.RawMessage
+		if _, err := c.Run(ctx, pageReq, &data); err != nil {
+			return err
+		}
+		info, page, err := extract(data)
+		if err != nil {
+			return fmt.Errorf("graphql: page %d: %v", pages, err)
+		}
+		if err := accumulate(page); err != nil {
+			return err
+		}
+		if !info.HasNextPage || p.maxPages > 0 && pages >= p.maxPages {
+			return nil
+		}
+		if info.EndCursor == "" {
+			return fmt.Errorf("graphql: page %d has a next page but no end cursor", pages)
+		}
+		pageReq = req.withCursor(cursorVar, info.EndCursor)
+	}
+}
+
+// withCursor gets a copy of req with the variable cursorVar set to cursor.
+func (req *Request) withCursor(cursorVar, cursor string) *Request {
+	next := *req
+	next.ownVars = false
+	next.strictVars = false
+	return next.WithVar(cursorVar, cursor)
+}
@@ -0,0 +1,287 @@
+package gqlclient
+
+import (
+	"strconv"
+	"strings"
+)
+
+// This file holds a minimal GraphQL lexer and the few bits of document
+// parsing the client needs for observability and routing. It is not a
+// validating parser: malformed documents yield whatever could be read.
+
+type tokenKind int
+
+const (
+	tokenPunct tokenKind = iota
+	tokenName
+	tokenNumber
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+
+	// pos is the byte offset of the token in the document
+	pos int
+}
+
+// is reports whether the token has the given kind and value.
+func (t token) is(kind tokenKind, value string) bool {
+	return t.kind == kind && t.value == value
+}
+
+// end is the byte offset just past the token in the document.
+func (t token) end() int {
+	return t.pos + len(t.value)
+}
+
+// tokenize splits a GraphQL document into tokens, dropping whitespace,
+// commas and comments.
+func tokenize(doc string) []token {
+	var tokens []token
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == ' ', c == '\t', c == '\n', c == '\r', c == ',':
+			i++
+		case strings.HasPrefix(doc[i:], "\uFEFF"):
+			i += len("\uFEFF")
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+		case c == '.' && i+2 < len(doc) && doc[i+1] == '.' && doc[i+2] == '.':
+			tokens = append(tokens, token{tokenPunct, "...", i})
+			i += 3
+		case isNameStart(c):
+			start := i
+			for i < len(doc) && (isNameStart(doc[i]) || isDigit(doc[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenName, doc[start:i], start})
+		case isDigit(c) || c == '-':
+			start := i
+			i++
+			for i < len(doc) && (isDigit(doc[i]) || doc[i] == '.' || doc[i] == 'e' || doc[i] == 'E' || doc[i] == '+' || doc[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, doc[start:i], start})
+		case c == '"':
+			start := i
+			if i+2 < len(doc) && doc[i+1] == '"' && doc[i+2] == '"' {
+				i += 3
+				for i < len(doc) {
+					if doc[i] == '\\' && i+3 < len(doc) && doc[i+1:i+4] == `"""` {
+						i += 4
+						continue
+					}
+					if i+2 < len(doc) && doc[i:i+3] == `"""` {
+						i += 3
+						break
+					}
+					i++
+				}
+			} else {
+				i++
+				for i < len(doc) && doc[i] != '"' && doc[i] != '\n' {
+					if doc[i] == '\\' {
+						i++
+					}
+					i++
+				}
+				i++
+			}
+			if i > len(doc) {
+				i = len(doc)
+			}
+			tokens = append(tokens, token{tokenString, doc[start:i], start})
+		default:
+			tokens = append(tokens, token{tokenPunct, string(c), i})
+			i++
+		}
+	}
+	return tokens
+}
+
+// normalizeQuery gets doc with its tokens separated by single spaces,
+// without comments or commas.
+func normalizeQuery(doc string) string {
+	tokens := tokenize(doc)
+	values := make([]string, len(tokens))
+	for i, t := range tokens {
+		values[i] = t.value
+	}
+	return strings.Join(values, " ")
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// operation is an operation definition of a GraphQL document.
+type operation struct {
+	// typ is query, mutation or subscription.
+	typ string
+
+	// name is empty for anonymous operations.
+	name string
+
+	// directives holds the arguments of the directives of the operation
+	// definition, by directive name.
+	directives map[string]map[string]string
+}
+
+// parseOperations returns the operation definitions of a GraphQL document,
+// in order. Fragment definitions are skipped.
+func parseOperations(doc string) []operation {
+	var ops []operation
+	tokens := tokenize(doc)
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind == tokenPunct {
+			switch t.value {
+			case "{":
+				if depth == 0 {
+					// shorthand query
+					ops = append(ops, operation{typ: "query"})
+				}
+				depth++
+			case "(", "[":
+				depth++
+			case "}", ")", "]":
+				depth--
+			}
+			continue
+		}
+		if depth != 0 || t.kind != tokenName {
+			continue
+		}
+		switch t.value {
+		case "query", "mutation", "subscription":
+			op := operation{typ: t.value}
+			if i+1 < len(tokens) && tokens[i+1].kind == tokenName {
+				op.name = tokens[i+1].value
+				i++
+			}
+			op.directives, i = parseDirectives(tokens, i+1)
+			ops = append(ops, op)
+			depth++
+		case "fragment":
+			i = selectionSetStart(tokens, i+1)
+			depth++
+		}
+	}
+	return ops
+}
+
+// selectionSetStart returns the index of the token opening the selection
+// set of the definition whose header starts at tokens[i], skipping over
+// variable definitions and directive arguments, which may hold object
+// values themselves.
+func selectionSetStart(tokens []token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		if tokens[i].kind != tokenPunct {
+			continue
+		}
+		switch tokens[i].value {
+		case "{":
+			if depth == 0 {
+				return i
+			}
+			depth++
+		case "(", "[":
+			depth++
+		case "}", ")", "]":
+			depth--
+		}
+	}
+	return i
+}
+
+// parseDirectives reads the directives of the definition whose header
+// starts at tokens[i], up to its selection set, and returns them with the
+// index of the token opening the selection set. Arguments with list or
+// object values are left out; variables are kept with their "$" prefix.
+func parseDirectives(tokens []token, i int) (map[string]map[string]string, int) {
+	var directives map[string]map[string]string
+	depth := 0
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != tokenPunct {
+			continue
+		}
+		switch t.value {
+		case "{":
+			if depth == 0 {
+				return directives, i
+			}
+			depth++
+		case "(", "[":
+			depth++
+		case "}", ")", "]":
+			depth--
+		case "@":
+			if depth != 0 || i+1 >= len(tokens) || tokens[i+1].kind != tokenName {
+				continue
+			}
+			if directives == nil {
+				directives = make(map[string]map[string]string)
+			}
+			args := make(map[string]string)
+			directives[tokens[i+1].value] = args
+			i++
+			if i+1 < len(tokens) && tokens[i+1].is(tokenPunct, "(") {
+				i = parseArguments(tokens, i+2, args)
+			}
+		}
+	}
+	return directives, i
+}
+
+// parseArguments reads the arguments starting at tokens[i] into args, and
+// returns the index of the closing parenthesis.
+func parseArguments(tokens []token, i int, args map[string]string) int {
+	for i+2 < len(tokens) && tokens[i].kind == tokenName && tokens[i+1].is(tokenPunct, ":") {
+		name, v := tokens[i].value, tokens[i+2]
+		i += 3
+		switch {
+		case v.kind == tokenString:
+			args[name] = stringValue(v.value)
+		case v.kind != tokenPunct:
+			args[name] = v.value
+		case v.value == "$" && i < len(tokens) && tokens[i].kind == tokenName:
+			args[name] = "$" + tokens[i].value
+			i++
+		case v.value == "{" || v.value == "[":
+			// skip list and object values
+			for depth := 1; depth > 0 && i < len(tokens); i++ {
+				switch tokens[i].value {
+				case "{", "[":
+					depth++
+				case "}", "]":
+					depth--
+				}
+			}
+		}
+	}
+	return i
+}
+
+// stringValue gets the value of a string token.
+func stringValue(s string) string {
+	if strings.HasPrefix(s, `"""`) {
+		s = strings.TrimSuffix(strings.TrimPrefix(s, `"""`), `"""`)
+		return strings.TrimSpace(strings.ReplaceAll(s, `\"""`, `"""`))
+	}
+	if v, err := strconv.Unquote(s); err == nil {
+		return v
+	}
+	return strings.Trim(s, `"`)
+}
@@ -0,0 +1,168 @@
+package gqlclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithRecorder sets a function called with every request sent by the
+// Client and its response, once the response is decoded, whether the
+// request succeeded or not. Recorded interactions can be saved as
+// fixtures and replayed in tests with WithCassette.
+//
+//	NewClient(endpoint, WithRecorder(func(rec Interaction) {
+//	    cassette = append(cassette, rec)
+//	}))
+//
+// Recording turns off StreamDecode, since the response body is kept.
+func WithRecorder(record func(rec Interaction)) ClientOption {
+	return func(client *Client) {
+		client.record = record
+	}
+}
+
+// Interaction is a request sent by the Client along with its response,
+// as recorded by WithRecorder.
+type Interaction struct {
+	// OperationName and Variables identify the request.
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+
+	RequestHeader http.Header `json:"requestHeader"`
+	RequestBody   string      `json:"requestBody"`
+
+	// StatusCode is zero if no response was received.
+	StatusCode     int         `json:"statusCode"`
+	ResponseHeader http.Header `json:"responseHeader,omitempty"`
+	ResponseBody   string      `json:"responseBody"`
+
+	// Duration is the time from sending the request to decoding the
+	// response.
+	Duration time.Duration `json:"duration"`
+
+	// Err is the error returned for the request, if any.
+	Err error `json:"-"`
+
+	start time.Time
+}
+
+// newInteraction starts recording r, built from req.
+func newInteraction(req *Request, r *http.Request) *Interaction {
+	rec := &Interaction{
+		OperationName: req.OperationName(),
+		Variables:     req.variables,
+		RequestHeader: r.Header.Clone(),
+		start:         time.Now(),
+	}
+	if r.GetBody != nil {
+		if body, err := r.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			body.Close()
+			rec.RequestBody = string(b)
+		}
+	}
+	return rec
+}
+
+// finish records the response res and error err.
+func (rec *Interaction) finish(res *http.Response, err error) {
+	rec.Duration = time.Since(rec.start)
+	rec.Err = err
+	if res != nil {
+		rec.StatusCode = res.StatusCode
+		rec.ResponseHeader = res.Header.Clone()
+	}
+}
+
+// WithCassette makes Run answer requests with the responses recorded in
+// cassette, without sending them. A request matches a recorded
+// interaction if they have the same operation name, the same variables
+// and the same recorded headers, except for the ones in
+// cassette.IgnoreHeaders. Requests matching no interaction fail if
+// cassette.Strict is set, and are sent as usual otherwise.
+//
+//	cassette, err := LoadCassette(f)
+//	cassette.Strict = true
+//	cassette.IgnoreHeaders = []string{"Authorization", "X-Trace-Id"}
+//	client := NewClient(endpoint, WithCassette(cassette))
+func WithCassette(cassette *Cassette) ClientOption {
+	return func(client *Client) {
+		client.cassette = cassette
+	}
+}
+
+// Cassette holds recorded interactions to replay with WithCassette.
+type Cassette struct {
+	Interactions []Interaction
+
+	// Strict makes requests matching no interaction fail, instead of
+	// being sent to the server.
+	Strict bool
+
+	// IgnoreHeaders lists the headers left out when matching requests,
+	// such as volatile tracing or authentication headers. Content-Type,
+	// which holds the random boundary of multipart requests, is always
+	// left out.
+	IgnoreHeaders []string
+}
+
+// LoadCassette reads a cassette from r, holding a JSON array of
+// interactions as recorded by WithRecorder.
+func LoadCassette(r io.Reader) (*Cassette, error) {
+	var cassette Cassette
+	if err := json.NewDecoder(r).Decode(&cassette.Interactions); err != nil {
+		return nil, errors.Wrap(err, "decoding cassette")
+	}
+	return &cassette, nil
+}
+
+// match gets the first interaction matching req, sent with header.
+func (c *Cassette) match(req *Request, header http.Header) (Interaction, bool) {
+	variables, err := json.Marshal(req.variables)
+	if err != nil {
+		return Interaction{}, false
+	}
+	for _, rec := range c.Interactions {
+		if rec.OperationName != req.OperationName() {
+			continue
+		}
+		// compare the encoded variables, which makes 1 and 1.0 equal
+		if recorded, err := json.Marshal(rec.Variables); err != nil || !bytes.Equal(recorded, variables) {
+			continue
+		}
+		if c.headersMatch(rec.RequestHeader, header) {
+			return rec, true
+		}
+	}
+	return Interaction{}, false
+}
+
+// headersMatch reports whether the recorded headers are the same in
+// header, except for the ignored ones.
+func (c *Cassette) headersMatch(recorded, header http.Header) bool {
+	for key, values := range recorded {
+		key = http.CanonicalHeaderKey(key)
+		if key == "Content-Type" || c.ignores(key) {
+			continue
+		}
+		if strings.Join(values, "\n") != strings.Join(header.Values(key), "\n") {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cassette) ignores(key string) bool {
+	for _, ignored := range c.IgnoreHeaders {
+		if http.CanonicalHeaderKey(ignored) == key {
+			return true
+		}
+	}
+	return false
+}
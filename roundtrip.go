@@ -0,0 +1,108 @@
+package gqlclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// requestKey is the context key of the Request an http.Request was built
+// from.
+type requestKey struct{}
+
+// RequestFromContext gets the Request being sent, from the context of the
+// http.Request built for it by the Client. It is nil for requests that
+// were not sent by a Client.
+func RequestFromContext(ctx context.Context) *Request {
+	req, _ := ctx.Value(requestKey{}).(*Request)
+	return req
+}
+
+// GraphQLRoundTripper is an http.RoundTripper for middleware that needs to
+// know which GraphQL operation a request is for, for example to name
+// tracing spans or label metrics:
+//
+//	httpClient := &http.Client{Transport: &gqlclient.GraphQLRoundTripper{
+//	    Wrap: func(operationName string, r *http.Request, next http.RoundTripper) (*http.Response, error) {
+//	        ctx, span := tracer.Start(r.Context(), "graphql "+operationName)
+//	        defer span.End()
+//	        return next.RoundTrip(r.WithContext(ctx))
+//	    },
+//	}}
+//	client := gqlclient.NewClient(endpoint, gqlclient.WithHTTPClient(httpClient))
+//
+// The http.Request it sees is the one fully built by the Client, with all
+// its headers and its encoded body.
+type GraphQLRoundTripper struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Wrap is called for every request with the name of its operation,
+	// as given by Request.OperationName, and must send it with next.
+	// If nil, requests are sent as is.
+	Wrap func(operationName string, r *http.Request, next http.RoundTripper) (*http.Response, error)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *GraphQLRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	next := t.Base
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if t.Wrap == nil {
+		return next.RoundTrip(r)
+	}
+	operationName, r := operationNameOf(r)
+	return t.Wrap(operationName, r, next)
+}
+
+// operationNameOf gets the name of the operation r is for, along with the
+// request to send. For requests not sent by a Client, it is read from JSON
+// bodies. A body that cannot be read again is buffered, and set on a clone
+// of r, as r itself must not be modified.
+func operationNameOf(r *http.Request) (string, *http.Request) {
+	if req := RequestFromContext(r.Context()); req != nil {
+		return req.OperationName(), r
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Body == nil || r.Body == http.NoBody || mediaType != "application/json" {
+		return "anonymous", r
+	}
+	var body io.Reader
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+		if err != nil {
+			return "anonymous", r
+		}
+		defer rc.Close()
+		body = rc
+	} else {
+		b, err := io.ReadAll(r.Body)
+		clone := r.Clone(r.Context())
+		if err != nil {
+			// let the transport fail on the rest of the body
+			clone.Body = readCloser{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+			return "anonymous", clone
+		}
+		r.Body.Close()
+		clone.Body = io.NopCloser(bytes.NewReader(b))
+		r = clone
+		body = bytes.NewReader(b)
+	}
+	var params struct {
+		Query         string `json:"query"`
+		OperationName string `json:"operationName"`
+	}
+	if err := json.NewDecoder(body).Decode(&params); err != nil {
+		return "anonymous", r
+	}
+	return NewRequest(params.Query).WithOperationName(params.OperationName).OperationName(), r
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
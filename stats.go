@@ -0,0 +1,145 @@
+package gqlclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Stats holds details about a request made with RunWithStats.
+type Stats struct {
+	// Response is the HTTP response, as returned by Run.
+	Response *http.Response
+
+	// RequestBytes is the size of the request body, including the whole
+	// multipart body for uploads. It is -1 when the body is streamed, as
+	// with StreamRequestBody.
+	RequestBytes int64
+
+	// ResponseBytes is the size of the response body as received. When
+	// the Client owns its transport and compression is not disabled, it
+	// asks for gzip itself, unless Accept-Encoding is already set, and
+	// counts a gzipped response before decompressing it. With a custom
+	// http.Client set by WithHTTPClient, its transport may decompress the
+	// response first, and this is then the decompressed size.
+	ResponseBytes int64
+
+	// Errors holds the GraphQL errors returned by the server.
+	Errors []GraphQLError
+
+	// Warnings holds the warnings returned by the server, as read by
+	// WithWarningHandler.
+	Warnings []Warning
+
+	// Trace holds the connection details of the request.
+	// It is only set when the Client was created with the WithHTTPTrace
+	// option.
+	Trace *Trace
+
+	// envelope receives the top-level fields of the response, for RunFull
+	envelope *map[string]json.RawMessage
+}
+
+// TLS gets the state of the TLS connection the response was received on,
+// including the negotiated version and cipher suite. It is nil if the
+// response was not received over TLS. For reused connections, this is the
+// state negotiated by the original handshake.
+func (s *Stats) TLS() *tls.ConnectionState {
+	if s.Response == nil {
+		return nil
+	}
+	return s.Response.TLS
+}
+
+// Trace holds the connection details of a request.
+type Trace struct {
+	// ConnReused reports whether the connection was reused from a
+	// previous request.
+	ConnReused bool
+
+	// DNS is the time spent resolving the host name.
+	DNS time.Duration
+
+	// Connect is the time spent establishing the TCP connection.
+	Connect time.Duration
+
+	// TLSHandshake is the time spent on the TLS handshake.
+	TLSHandshake time.Duration
+}
+
+// RunWithStats executes the query like Run, and returns details about the
+// request alongside the HTTP response.
+// The Stats are returned even if the request fails, as far as they
+// were collected.
+//
+//	stats, err := client.RunWithStats(ctx, req, &responseData)
+//	if stats.Trace != nil && !stats.Trace.ConnReused {
+//	    log.Println("opened a new connection")
+//	}
+func (c *Client) RunWithStats(ctx context.Context, req *Request, resp interface{}) (*Stats, error) {
+	stats := &Stats{}
+	res, err := c.run(ctx, req, resp, stats)
+	stats.Response = res
+	return stats, err
+}
+
+// tracer records the timings reported by an httptrace.ClientTrace.
+// Dials may complete in the background after the request got another
+// connection, so access is guarded by a mutex.
+type tracer struct {
+	mu                               sync.Mutex
+	trace                            Trace
+	dnsStart, connectStart, tlsStart time.Time
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.ConnReused = info.Reused
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.DNS = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.Connect = time.Since(t.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.TLSHandshake = time.Since(t.tlsStart)
+		},
+	}
+}
+
+// snapshot returns a copy of the timings recorded so far.
+func (t *tracer) snapshot() *Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trace := t.trace
+	return &trace
+}
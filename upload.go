@@ -0,0 +1,131 @@
+package gqlclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// UploadRequest builds a Request uploading files with the standard
+// multipart layout, in which every file stands for a variable, or a
+// position within one, that is sent as null:
+//
+//	req := gqlclient.NewUploadRequest(`mutation ($input: UploadInput!) { upload(input: $input) { id } }`).
+//	    Var("input", input).
+//	    Attach("input.file", "avatar.png", f).
+//	    Request()
+//
+// Paths are dotted, with list positions given by their index, such as
+// "input.files.0". Request checks that every path points into a variable
+// set with Var.
+type UploadRequest struct {
+	query     string
+	variables map[string]interface{}
+	files     []File
+}
+
+// NewUploadRequest makes a new UploadRequest with the specified string as
+// query.
+func NewUploadRequest(query string) *UploadRequest {
+	return &UploadRequest{
+		query:     query,
+		variables: make(map[string]interface{}),
+	}
+}
+
+// Var sets a variable of the request. Files attached within it replace
+// the values at their paths.
+func (u *UploadRequest) Var(name string, value interface{}) *UploadRequest {
+	u.variables[name] = value
+	return u
+}
+
+// Attach adds a file to upload at path, a variable or a position within
+// one.
+func (u *UploadRequest) Attach(path, filename string, r io.Reader) *UploadRequest {
+	u.files = append(u.files, File{
+		Field: path,
+		Name:  filename,
+		R:     r,
+	})
+	return u
+}
+
+// Request builds the Request, sent as multipart with the standard layout
+// whatever the options of the Client. If a path does not point into a
+// variable, Run and Validate return an error without sending it.
+func (u *UploadRequest) Request() *Request {
+	req := NewRequest(u.query).ForceMultipart().WithUploadSpec(UploadSpecStandard)
+	variables, err := u.resolveVariables()
+	if err != nil {
+		req.err = err
+		return req
+	}
+	req.WithVars(variables)
+	for _, f := range u.files {
+		f.Field = "variables." + f.Field
+		req.files = append(req.files, f)
+	}
+	return req
+}
+
+// resolveVariables gets the variables of the request, as decoded from
+// JSON, with null at the path of every file.
+func (u *UploadRequest) resolveVariables() (map[string]interface{}, error) {
+	b, err := json.Marshal(u.variables)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: encoding variables: %v", err)
+	}
+	// numbers are kept as json.Number, as float64 would round large
+	// integers such as IDs
+	var variables map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&variables); err != nil {
+		return nil, fmt.Errorf("graphql: encoding variables: %v", err)
+	}
+	for _, f := range u.files {
+		if err := setNull(variables, f.Field); err != nil {
+			return nil, err
+		}
+	}
+	return variables, nil
+}
+
+// setNull sets the value at path within variables to null. Every step
+// of path but the last must exist, and the last must be a key of an
+// object or an index of a list.
+func setNull(variables map[string]interface{}, path string) error {
+	keys := strings.Split(path, ".")
+	if _, ok := variables[keys[0]]; !ok {
+		return fmt.Errorf("graphql: upload path %q does not match a variable", path)
+	}
+	var parent interface{} = variables
+	for i, key := range keys {
+		last := i == len(keys)-1
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if last {
+				p[key] = nil
+				return nil
+			}
+			parent = p[key]
+		case []interface{}:
+			n, err := strconv.Atoi(key)
+			if err != nil || n < 0 || n >= len(p) {
+				return fmt.Errorf("graphql: upload path %q does not match a variable: no list position %q", path, key)
+			}
+			if last {
+				p[n] = nil
+				return nil
+			}
+			parent = p[n]
+		default:
+			return fmt.Errorf("graphql: upload path %q does not match a variable: %q is not an object or a list", path, strings.Join(keys[:i], "."))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package gqlclient
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Vars builds the variables of a Request.
+//
+//	vars := gqlclient.Vars{}.
+//	    Set("name", "lelebus").
+//	    SetInt("limit", 10).
+//	    SetEnum("order", "DESC")
+//	req := gqlclient.NewRequest(query).WithVars(vars)
+type Vars map[string]interface{}
+
+// Set sets the variable key to value.
+func (v Vars) Set(key string, value interface{}) Vars {
+	if v == nil {
+		v = Vars{}
+	}
+	v[key] = value
+	return v
+}
+
+// SetInt sets the variable key to the Int value.
+func (v Vars) SetInt(key string, value int) Vars {
+	return v.Set(key, value)
+}
+
+// SetEnum sets the variable key to the enum value name.
+func (v Vars) SetEnum(key string, name string) Vars {
+	return v.Set(key, Enum(name))
+}
+
+// MergeVars merges maps of variables into a new map, later maps taking
+// precedence over earlier ones, for example to combine defaults with
+// user input:
+//
+//	req.WithVars(gqlclient.MergeVars(defaults, input))
+//
+// Nested maps, such as input objects, are merged recursively, key by key,
+// instead of being replaced. Any other value, including lists, replaces
+// the value from earlier maps. The maps given are not modified.
+func MergeVars(maps ...map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for _, m := range maps {
+		mergeInto(merged, m)
+	}
+	return merged
+}
+
+// mergeInto merges src into dst, copying the nested maps of src so that
+// later merges into dst never modify them.
+func mergeInto(dst, src map[string]interface{}) {
+	for key, value := range src {
+		nested, ok := asMap(value)
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		target, ok := asMap(dst[key])
+		if !ok {
+			target = make(map[string]interface{}, len(nested))
+			dst[key] = target
+		}
+		mergeInto(target, nested)
+	}
+}
+
+// asMap gets v as a map of variables, if it is one.
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case Vars:
+		return m, true
+	}
+	return nil, false
+}
+
+// Enum is a GraphQL enum value.
+//
+// In variables, the GraphQL spec has enum values travel as JSON strings,
+// which the server coerces to the enum type declared for the variable.
+// Enum checks that the value is a valid enum name when it is encoded, so
+// a typo such as "in progress" fails before the request is sent instead
+// of being rejected by the server.
+type Enum string
+
+// MarshalJSON encodes the enum value as a JSON string, after checking that
+// it is a valid GraphQL enum name.
+func (e Enum) MarshalJSON() ([]byte, error) {
+	if !isEnumName(string(e)) {
+		return nil, fmt.Errorf("graphql: invalid enum value %q", string(e))
+	}
+	return json.Marshal(string(e))
+}
+
+// isEnumName reports whether s is a GraphQL name other than true, false
+// and null.
+func isEnumName(s string) bool {
+	switch s {
+	case "", "true", "false", "null":
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}